@@ -0,0 +1,445 @@
+package wayang
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// the default duration that an assertion waits for to pass
+	assertTimeout = 5 * time.Second
+
+	// the interval between the checks of an assertion
+	assertInterval = 100 * time.Millisecond
+)
+
+// AssertionFailure is the error of a failed assert action. The RuntimeError of the action holds it, see RuntimeError.Assertion.
+type AssertionFailure struct {
+	// Source is where the assert action is in the program, such as root[2].assert
+	Source string `json:"source"`
+
+	// Message is the message of the assert action
+	Message string `json:"message,omitempty"`
+
+	// Comparison is the comparison that failed, or "statement" when the statement didn't return true
+	Comparison string      `json:"comparison"`
+	Expected   interface{} `json:"expected"`
+	Actual     interface{} `json:"actual"`
+
+	// Err is why the values could not be compared, such as the error of the actual action
+	Err string `json:"error,omitempty"`
+}
+
+func (f *AssertionFailure) Error() string {
+	var msg string
+	switch {
+	case f.Comparison == "statement" && f.Err != "":
+		msg = "the statement failed: " + f.Err
+	case f.Comparison == "statement":
+		msg = fmt.Sprintf("expected the statement to return true, got %s", display(f.Actual))
+	case f.Err != "":
+		msg = fmt.Sprintf("could not check that the actual value would %s %s: %s",
+			comparisons[f.Comparison].verb, display(f.Expected), f.Err)
+	default:
+		msg = fmt.Sprintf("expected %s to %s %s", display(f.Actual), comparisons[f.Comparison].verb, display(f.Expected))
+	}
+
+	if f.Message != "" {
+		return f.Message + ": " + msg
+	}
+	return msg
+}
+
+// display is the value as JSON, such as a string with its quotes
+func display(value interface{}) string {
+	bin, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(bin)
+}
+
+type comparison struct {
+	// verb completes "expected <actual> to", e.g. "be greater than"
+	verb    string
+	compare func(actual, expected interface{}, ignoreCase bool) (bool, error)
+}
+
+var comparisons = map[string]comparison{
+	"contains":    {"contain", containsComparison},
+	"equals":      {"equal", equalsComparison},
+	"greaterThan": {"be greater than", greaterThanComparison},
+	"lessThan":    {"be less than", lessThanComparison},
+	"matches":     {"match", matchesComparison},
+}
+
+// comparisonNames are the names of the comparisons in alphabetical order
+func comparisonNames() []string {
+	names := []string{}
+	for name := range comparisons {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func assertAction(ra runtimeAction, act Action) interface{} {
+	_, hasStatement := act["statement"]
+	if _, ok := act["actual"]; !ok && !hasStatement {
+		return ra.err("an 'actual' key or a 'statement' key (type action) is required to be present")
+	}
+	if !hasStatement {
+		found := false
+		for _, name := range comparisonNames() {
+			_, ok := act[name]
+			found = found || ok
+		}
+		if !found {
+			return ra.err("one of the " + strings.Join(comparisonNames(), ", ") + " keys is required to be present")
+		}
+	}
+
+	timeout := assertTimeout
+	if duration, ok := act["duration"].(float64); ok {
+		timeout = time.Duration(float64(time.Second) * duration)
+	}
+	deadline := time.Now().Add(timeout)
+
+	for {
+		failure := ra.checkBefore(act, deadline)
+		if failure == nil {
+			return nil
+		}
+		if !time.Now().Add(assertInterval).Before(deadline) {
+			return ra.fail(act, failure)
+		}
+
+		select {
+		case <-ra.page.GetContext().Done():
+			return ra.fail(act, failure)
+		case <-time.After(assertInterval):
+		}
+	}
+}
+
+// checkBefore checks the assertion once on a page that times out at the deadline, so that a missing element
+// doesn't make the assertion wait for longer. When the deadline has already passed, such as with a duration of 0,
+// the check runs fully on the page of the action instead.
+func (ra runtimeAction) checkBefore(act Action, deadline time.Time) *AssertionFailure {
+	if !time.Now().Before(deadline) {
+		return ra.check(act)
+	}
+
+	ctx, cancel := context.WithDeadline(ra.page.GetContext(), deadline)
+	defer cancel()
+
+	ra.page = ra.page.Context(ctx, cancel)
+	return ra.check(act)
+}
+
+// fail returns the error of the failure, or records it and lets the program continue when the assertion is soft
+func (ra runtimeAction) fail(act Action, failure *AssertionFailure) interface{} {
+	run := ra.runner
+
+	soft := run.program.SoftAssertions
+	if value, ok := act["soft"].(bool); ok {
+		soft = value
+	}
+	if !soft {
+		return ra.err(failure)
+	}
+
+	run.Error("soft assertion failed: " + failure.Error())
+	run.soft = append(run.soft, ra.err(failure))
+	return nil
+}
+
+// softReport is the error of a program whose soft assertions failed
+func (parent *Runner) softReport() RuntimeError {
+	lines := []string{fmt.Sprintf("%d soft assertions failed:", len(parent.soft))}
+	for _, rErr := range parent.soft {
+		failure := rErr.Assertion()
+		lines = append(lines, failure.Source+": "+failure.Error())
+	}
+
+	ra := runtimeAction{runner: parent, source: "assertions"}
+	rErr := ra.err(strings.Join(lines, "\n"))
+	rErr.failures = parent.failures()
+	return rErr
+}
+
+// failures are the failures of the soft assertions of the program
+func (parent *Runner) failures() []*AssertionFailure {
+	list := []*AssertionFailure{}
+	for _, rErr := range parent.soft {
+		list = append(list, rErr.Assertion())
+	}
+	return list
+}
+
+// check the assertion once, it returns nil when the assertion passes
+func (ra runtimeAction) check(act Action) *AssertionFailure {
+	message, _ := act["message"].(string)
+
+	if statement, ok := act["statement"]; ok {
+		failure := &AssertionFailure{Source: ra.source, Message: message, Comparison: "statement", Expected: true}
+		res, err := ra.value(statement)
+		switch {
+		case err != "":
+			failure.Err = err
+		case res != true:
+			failure.Actual = res
+		default:
+			return nil
+		}
+		return failure
+	}
+
+	actual, actualErr := ra.value(act["actual"])
+	ignoreCase, _ := act["ignoreCase"].(bool)
+	for _, name := range comparisonNames() {
+		raw, ok := act[name]
+		if !ok {
+			continue
+		}
+		failure := &AssertionFailure{Source: ra.source, Message: message, Comparison: name, Actual: actual, Err: actualErr}
+
+		expected, err := ra.value(raw)
+		failure.Expected = expected
+		if failure.Err != "" {
+			return failure
+		}
+		if err != "" {
+			failure.Err = "the expected value failed: " + err
+			return failure
+		}
+
+		passed, compareErr := comparisons[name].compare(actual, expected, ignoreCase)
+		if compareErr != nil {
+			failure.Err = compareErr.Error()
+			return failure
+		}
+		if !passed {
+			return failure
+		}
+	}
+	return nil
+}
+
+// value is the result of the action, the value of the custom action or the store key that a "$name" refers to,
+// or the value itself. The error is the one of the action.
+func (ra runtimeAction) value(value interface{}) (res interface{}, err string) {
+	run := ra.runner
+
+	if name, ok := value.(string); ok && strings.HasPrefix(name, "$") {
+		key := strings.TrimPrefix(name, "$")
+		if action, ok := run.program.Actions[key]; ok {
+			value = action
+		} else if stored, ok := run.ENV[key]; ok {
+			return stored, ""
+		}
+	}
+
+	stmt := run.makeAction(value)
+	if stmt == nil {
+		return value, ""
+	}
+
+	// the rod sugar that the actions use panics, such as when the page times out while waiting for an element
+	defer func() {
+		if r := recover(); r != nil {
+			res, err = nil, fmt.Sprint(r)
+		}
+	}()
+	res = ra.runAction(*stmt, ra.source)
+	if rErr, ok := res.(RuntimeError); ok {
+		return nil, strings.TrimSpace(rErr.Error())
+	}
+	return res, ""
+}
+
+// normalize the value through JSON, so that the results of the actions compare with the values of a program
+func normalize(value interface{}) interface{} {
+	bin, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var res interface{}
+	if err := json.Unmarshal(bin, &res); err != nil {
+		return value
+	}
+	return res
+}
+
+func foldCase(value interface{}, ignoreCase bool) interface{} {
+	if s, ok := value.(string); ok && ignoreCase {
+		return strings.ToLower(s)
+	}
+	return value
+}
+
+// toNumber converts numbers and the text of numbers to float64, see parseNumber
+func toNumber(value interface{}) (float64, bool) {
+	switch v := normalize(value).(type) {
+	case float64:
+		return v, true
+	case string:
+		return parseNumber(v)
+	}
+	return 0, false
+}
+
+// numberRegex matches the first number in a text, whose digits may be separated by one space, no-break space,
+// apostrophe, comma or dot
+var numberRegex = regexp.MustCompile(`[-\x{2212}]?\d+(?:[ \x{00a0}',.]\d+)*`)
+
+// numberSeparator is a separator in a match of numberRegex
+var numberSeparator = regexp.MustCompile(`[ \x{00a0}',.]`)
+
+// parseNumber parses the first number in a text, such as "$1,234.50", "1.234,50 €", "1 234" or "-3 %". The last
+// separator is the decimal separator when it is a comma or a dot that differs from the separator before it, or
+// that is the only one and isn't followed by three digits, such as in "3.5", unless the integer part is zero, such
+// as in "0.125". The other separators are the same group separator, each followed by three digits.
+// A number that is grouped otherwise, such as "1.2.3", isn't parsed.
+func parseNumber(text string) (float64, bool) {
+	match := numberRegex.FindString(text)
+	if match == "" {
+		return 0, false
+	}
+	match = strings.Replace(match, "\u2212", "-", 1)
+
+	groups := numberSeparator.Split(match, -1)
+	seps := numberSeparator.FindAllString(match, -1)
+
+	fraction := ""
+	if last := len(seps) - 1; last >= 0 && (seps[last] == "," || seps[last] == ".") {
+		if last > 0 && seps[last] != seps[last-1] ||
+			last == 0 && (len(groups[1]) != 3 || strings.TrimPrefix(groups[0], "-") == "0") {
+			fraction = groups[last+1]
+			groups, seps = groups[:last+1], seps[:last]
+		}
+	}
+
+	for i, sep := range seps {
+		if sep != seps[0] || len(groups[i+1]) != 3 {
+			return 0, false
+		}
+	}
+	if integer := strings.TrimPrefix(groups[0], "-"); len(seps) > 0 && (len(integer) > 3 || integer == "0") {
+		return 0, false
+	}
+
+	number := strings.Join(groups, "")
+	if fraction != "" {
+		number += "." + fraction
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	return n, err == nil
+}
+
+// toText converts strings and numbers to a string
+func toText(value interface{}) (string, bool) {
+	switch v := normalize(value).(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	}
+	return "", false
+}
+
+func equalsComparison(actual, expected interface{}, ignoreCase bool) (bool, error) {
+	a, e := normalize(actual), normalize(expected)
+
+	// a number equals the text of the same number, such as the text of an element
+	an, aNumber := a.(float64)
+	en, eNumber := e.(float64)
+	if aNumber != eNumber {
+		if aNumber {
+			n, err := strconv.ParseFloat(strings.TrimSpace(fmt.Sprint(e)), 64)
+			return err == nil && n == an, nil
+		}
+		n, err := strconv.ParseFloat(strings.TrimSpace(fmt.Sprint(a)), 64)
+		return err == nil && n == en, nil
+	}
+
+	return reflect.DeepEqual(foldCase(a, ignoreCase), foldCase(e, ignoreCase)), nil
+}
+
+func containsComparison(actual, expected interface{}, ignoreCase bool) (bool, error) {
+	switch a := normalize(actual).(type) {
+	case []interface{}:
+		for _, item := range a {
+			if equal, _ := equalsComparison(item, expected, ignoreCase); equal {
+				return true, nil
+			}
+		}
+		return false, nil
+	case map[string]interface{}:
+		key, ok := toText(expected)
+		if !ok {
+			return false, fmt.Errorf("the keys of an object are strings, got %s", display(expected))
+		}
+		_, has := a[key]
+		return has, nil
+	}
+
+	a, ok := toText(actual)
+	if !ok {
+		return false, fmt.Errorf("expected the actual value to be a string, an array or an object, got %s", display(actual))
+	}
+	e, ok := toText(expected)
+	if !ok {
+		return false, fmt.Errorf("expected a string, got %s", display(expected))
+	}
+	return strings.Contains(foldCase(a, ignoreCase).(string), foldCase(e, ignoreCase).(string)), nil
+}
+
+func matchesComparison(actual, expected interface{}, ignoreCase bool) (bool, error) {
+	pattern, ok := expected.(string)
+	if !ok {
+		return false, fmt.Errorf("expected a regular expression string, got %s", display(expected))
+	}
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return false, err
+	}
+
+	text, ok := toText(actual)
+	if !ok {
+		return false, fmt.Errorf("expected the actual value to be a string, got %s", display(actual))
+	}
+	return regex.MatchString(text), nil
+}
+
+func greaterThanComparison(actual, expected interface{}, _ bool) (bool, error) {
+	a, e, err := numbers(actual, expected)
+	return a > e, err
+}
+
+func lessThanComparison(actual, expected interface{}, _ bool) (bool, error) {
+	a, e, err := numbers(actual, expected)
+	return a < e, err
+}
+
+func numbers(actual, expected interface{}) (float64, float64, error) {
+	a, ok := toNumber(actual)
+	if !ok {
+		return 0, 0, fmt.Errorf("expected the actual value to be a number, got %s", display(actual))
+	}
+	e, ok := toNumber(expected)
+	if !ok {
+		return 0, 0, fmt.Errorf("expected a number, got %s", display(expected))
+	}
+	return a, e, nil
+}
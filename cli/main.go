@@ -1,10 +1,15 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/go-rod/rod/lib/cdp"
@@ -16,35 +21,77 @@ import (
 
 var (
 	headless   = flag.Bool("headless", true, "decide between whether to run chrome in windowed mode or not")
-	filePath   = flag.String("file", "", "*the location of the file which will be executed")
+	filePath   = flag.String("file", "", "*the location of the JSON or YAML file which will be executed, - reads it from stdin")
 	verbose    = flag.Bool("verbose", false, "verbose logging information")
 	output     = flag.Bool("output", true, "print JSON output to stdout")
 	outputFile = flag.String("outputFile", "", "the file location of the output json")
 	timeout    = flag.Int("timeout", 30, "timeout for program")
 	store      = flag.String("store", "store.json", "the file location to store the program environment after execution")
+	artifacts  = flag.String("artifacts", "", "the directory that files written by the program, such as screenshots, are relative to")
+	snapshots  = flag.String("snapshots", "snapshots", "the directory of the baseline screenshots used by matchScreenshot")
+	update     = flag.Bool("updateSnapshots", false, "overwrite the baseline screenshots instead of comparing with them")
+	har        = flag.String("har", "", "the file location to record the requests of the browser to, in the HAR format")
+	state      = flag.String("state", "", "a state file written by the saveState action to load before the steps run")
+	replay     = flag.String("replay", "", "the HAR file to answer the requests of the page from, other requests are aborted")
+	device     = flag.String("device", "", "the name of a device preset to emulate, it overrides the device of the program")
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "test" {
+		testCommand(os.Args[2:])
+		return
+	}
 	flag.Parse()
 
+	if *filePath == "" && piped() {
+		*filePath = "-"
+	}
 	if *filePath == "" {
-		log.Fatal("You must provide a file path to a JSON file.")
+		log.Fatal("You must provide a file path to a JSON or YAML file, or pipe the program to stdin.")
+	}
+
+	program, readRes := readProgram(*filePath)
+	if readRes != nil {
+		log.Fatal("Error while reading to input file:", readRes)
+	}
+	if *device != "" {
+		program.Device = &wayang.Device{Name: *device}
 	}
 
 	url := launcher.New().Headless(*headless).Launch()
 	runner := wayang.NewRemoteRunner(cdp.New(url))
 	defer runner.Close()
+	runner.ArtifactsDir = *artifacts
+	runner.SnapshotsDir = *snapshots
+	runner.UpdateSnapshots = *update
 
-	var program wayang.Program
-	readRes := kit.ReadJSON(*filePath, &program)
-	if readRes != nil {
-		log.Fatal("Error while reading to input file:", readRes)
+	if *har != "" {
+		runner.RecordHAR()
+	}
+	if *replay != "" {
+		replayRes := runner.Route(&wayang.Route{HAR: *replay, Abort: true})
+		if replayRes != nil {
+			log.Fatal("Error while reading the HAR file to replay:", replayRes)
+		}
 	}
 
 	timeout := time.Duration(*timeout)
 	runner.P = runner.P.Timeout(timeout * time.Second)
 
+	if *state != "" {
+		stateRes := runner.LoadState(*state)
+		if stateRes != nil {
+			log.Fatal("Error while loading the state file:", stateRes)
+		}
+	}
+
 	res, err := runner.RunProgram(program)
+	if *har != "" {
+		writeRes := runner.SaveHAR(*har)
+		if writeRes != nil {
+			log.Fatal("Error while writing the HAR file:", writeRes)
+		}
+	}
 	if *store != "" {
 		writeRes := kit.OutputFile(*store, runner.ENV, nil)
 		if writeRes != nil {
@@ -54,6 +101,15 @@ func main() {
 
 	if err != nil {
 		log.Print(err.Error())
+		if snap := err.Snapshot(); snap != nil {
+			paths, writeRes := snap.Write(filepath.Join(*artifacts, "failure"))
+			if writeRes != nil {
+				log.Print("Error while writing the failure snapshot:", writeRes)
+			}
+			for _, path := range paths {
+				log.Print("Failure snapshot written to ", path)
+			}
+		}
 		if *outputFile != "" {
 			writeRes := kit.OutputFile(*outputFile, err, nil)
 			if writeRes != nil {
@@ -91,3 +147,30 @@ func main() {
 		}
 	}
 }
+
+// piped reports whether the stdin is a pipe or a file instead of a terminal
+func piped() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice == 0
+}
+
+// readProgram reads the program from the file, or from stdin when the path is -
+func readProgram(path string) (wayang.Program, error) {
+	if path != "-" {
+		return wayang.ReadProgram(path)
+	}
+
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return wayang.Program{}, err
+	}
+	// a closed or empty stdin, such as the one of a cron job, is a missing program rather than an empty one
+	if len(bytes.TrimSpace(data)) == 0 {
+		return wayang.Program{}, errors.New("nothing was read from stdin, provide the program with -file or pipe it to stdin")
+	}
+	program, err := wayang.ParseProgram(data, "")
+	if err != nil {
+		return program, err
+	}
+	return program.ResolveImports(".")
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-rod/wayang"
+)
+
+// reporter writes the results of the test command
+type reporter interface {
+	// result is called when a test case completes
+	result(c *testCase)
+
+	// summary is called once every test case completed
+	summary(cases []*testCase, duration time.Duration) error
+}
+
+var reporterFormats = map[string]func(w io.Writer) reporter{
+	"pretty": func(w io.Writer) reporter { return &prettyReporter{w} },
+	"json":   func(w io.Writer) reporter { return &jsonReporter{w} },
+	"junit":  func(w io.Writer) reporter { return &junitReporter{w} },
+	"tap":    func(w io.Writer) reporter { return &tapReporter{w: w} },
+}
+
+// newReporters returns the reporter of the format, which writes to the file or stdout. When it writes to a file
+// the pretty results are printed too, so that the progress is still visible.
+func newReporters(format, file string) ([]reporter, func() error, error) {
+	newReporter, ok := reporterFormats[format]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown reporter %s, the reporters are pretty, json, junit and tap", format)
+	}
+	if file == "" {
+		return []reporter{newReporter(os.Stdout)}, func() error { return nil }, nil
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	reporters := []reporter{newReporter(f)}
+	if format != "pretty" {
+		reporters = append(reporters, &prettyReporter{os.Stdout})
+	}
+	return reporters, f.Close, nil
+}
+
+// message is the error of a test case or a step on a single line
+func message(err error) string {
+	msg := strings.Join(strings.Fields(err.Error()), " ")
+	if rErr, ok := err.(*wayang.RuntimeError); ok {
+		msg = rErr.Source() + ": " + msg
+	}
+	return msg
+}
+
+// stepName is the source of the step and its action, such as root[2].click
+func stepName(step wayang.StepResult) string {
+	return step.Source + "." + step.Action
+}
+
+// count the test cases by status
+func count(cases []*testCase) map[string]int {
+	res := map[string]int{}
+	for _, c := range cases {
+		res[c.status]++
+	}
+	return res
+}
+
+type prettyReporter struct {
+	w io.Writer
+}
+
+var prettyStatus = map[string]string{
+	statusPass: "PASS",
+	statusFail: "FAIL",
+	statusSkip: "SKIP",
+}
+
+func (r *prettyReporter) result(c *testCase) {
+	if c.status == statusSkip {
+		fmt.Fprintf(r.w, "%s  %s\n", prettyStatus[c.status], c.file)
+		return
+	}
+	fmt.Fprintf(r.w, "%s  %s (%.2fs)\n", prettyStatus[c.status], c.file, c.duration.Seconds())
+	if c.status != statusFail {
+		return
+	}
+
+	// the steps of a failed test case show where it stopped
+	for _, step := range c.steps {
+		if step.Status == wayang.StepSkipped {
+			fmt.Fprintf(r.w, "      %-7s %s\n", step.Status, stepName(step))
+			continue
+		}
+		fmt.Fprintf(r.w, "      %-7s %s (%.2fs)\n", step.Status, stepName(step), step.Duration.Seconds())
+		for _, path := range step.Screenshots {
+			fmt.Fprintf(r.w, "              screenshot %s\n", path)
+		}
+	}
+	fmt.Fprintf(r.w, "      %s\n", message(c.err))
+	for _, path := range c.snapshot {
+		fmt.Fprintf(r.w, "      failure snapshot written to %s\n", path)
+	}
+}
+
+func (r *prettyReporter) summary(cases []*testCase, duration time.Duration) error {
+	n := count(cases)
+	_, err := fmt.Fprintf(r.w, "\n%d passed, %d failed, %d skipped (%.2fs)\n",
+		n[statusPass], n[statusFail], n[statusSkip], duration.Seconds())
+	return err
+}
+
+type jsonReporter struct {
+	w io.Writer
+}
+
+type jsonReport struct {
+	Passed   int        `json:"passed"`
+	Failed   int        `json:"failed"`
+	Skipped  int        `json:"skipped"`
+	Duration float64    `json:"duration"`
+	Programs []jsonCase `json:"programs"`
+}
+
+type jsonCase struct {
+	File     string     `json:"file"`
+	Status   string     `json:"status"`
+	Duration float64    `json:"duration"`
+	Error    string     `json:"error,omitempty"`
+	Snapshot []string   `json:"snapshot,omitempty"`
+	Steps    []jsonStep `json:"steps"`
+}
+
+type jsonStep struct {
+	Source      string   `json:"source"`
+	Action      string   `json:"action"`
+	Status      string   `json:"status"`
+	Duration    float64  `json:"duration"`
+	Error       string   `json:"error,omitempty"`
+	Screenshots []string `json:"screenshots,omitempty"`
+}
+
+func (r *jsonReporter) result(*testCase) {}
+
+func (r *jsonReporter) summary(cases []*testCase, duration time.Duration) error {
+	n := count(cases)
+	report := jsonReport{
+		Passed:   n[statusPass],
+		Failed:   n[statusFail],
+		Skipped:  n[statusSkip],
+		Duration: duration.Seconds(),
+		Programs: []jsonCase{},
+	}
+	for _, c := range cases {
+		item := jsonCase{
+			File:     c.file,
+			Status:   c.status,
+			Duration: c.duration.Seconds(),
+			Snapshot: c.snapshot,
+			Steps:    []jsonStep{},
+		}
+		if c.err != nil {
+			item.Error = message(c.err)
+		}
+		for _, step := range c.steps {
+			s := jsonStep{
+				Source:      step.Source,
+				Action:      step.Action,
+				Status:      step.Status,
+				Duration:    step.Duration.Seconds(),
+				Screenshots: step.Screenshots,
+			}
+			if step.Err != nil {
+				s.Error = message(step.Err)
+			}
+			item.Steps = append(item.Steps, s)
+		}
+		report.Programs = append(report.Programs, item)
+	}
+
+	bin, err := json.MarshalIndent(report, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(r.w, string(bin))
+	return err
+}
+
+// junitReporter writes a test suite for every program, with a test case for every step
+type junitReporter struct {
+	w io.Writer
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+
+	// SystemOut holds the attachments in the format of the JUnit attachments plugin of Jenkins
+	SystemOut string `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func junitTime(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+func junitAttachments(paths []string) string {
+	res := ""
+	for _, path := range paths {
+		res += "[[ATTACHMENT|" + path + "]]\n"
+	}
+	return res
+}
+
+func (r *junitReporter) result(*testCase) {}
+
+func (r *junitReporter) summary(cases []*testCase, duration time.Duration) error {
+	report := junitSuites{Time: junitTime(duration)}
+	for _, c := range cases {
+		suite := junitSuite{Name: c.file, Time: junitTime(c.duration)}
+		failed := false
+		for _, step := range c.steps {
+			tc := junitCase{
+				Name:      stepName(step),
+				Classname: c.file,
+				Time:      junitTime(step.Duration),
+				SystemOut: junitAttachments(step.Screenshots),
+			}
+			switch step.Status {
+			case wayang.StepFailed:
+				failed = true
+				tc.Failure = &junitFailure{Message: message(step.Err), Text: strings.TrimSpace(step.Err.Error())}
+				tc.SystemOut += junitAttachments(c.snapshot)
+			case wayang.StepSkipped:
+				tc.Skipped = &struct{}{}
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+
+		// the failures that no step has, such as the ones of the setup, and the programs that didn't run
+		// are reported as a test case of the whole program
+		if c.status == statusFail && !failed || c.status == statusSkip && len(c.steps) == 0 {
+			tc := junitCase{Name: "program", Classname: c.file, Time: junitTime(c.duration)}
+			if c.status == statusSkip {
+				tc.Skipped = &struct{}{}
+			} else {
+				tc.Failure = &junitFailure{Message: message(c.err), Text: strings.TrimSpace(c.err.Error())}
+				tc.SystemOut = junitAttachments(c.snapshot)
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+
+		for _, tc := range suite.Cases {
+			suite.Tests++
+			if tc.Failure != nil {
+				suite.Failures++
+			}
+			if tc.Skipped != nil {
+				suite.Skipped++
+			}
+		}
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Skipped += suite.Skipped
+		report.Suites = append(report.Suites, suite)
+	}
+
+	bin, err := xml.MarshalIndent(report, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(r.w, xml.Header+string(bin))
+	return err
+}
+
+// tapReporter writes a test point for every program, with the steps as its subtests
+type tapReporter struct {
+	w io.Writer
+	n int
+}
+
+func (r *tapReporter) result(c *testCase) {
+	if r.n == 0 {
+		fmt.Fprintln(r.w, "TAP version 13")
+	}
+	r.n++
+
+	if len(c.steps) > 0 {
+		fmt.Fprintf(r.w, "    # Subtest: %s\n", c.file)
+		for i, step := range c.steps {
+			var err error
+			if step.Err != nil {
+				err = step.Err
+			}
+			r.point("    ", i+1, stepName(step), step.Status, step.Duration, err, step.Screenshots)
+		}
+		fmt.Fprintf(r.w, "    1..%d\n", len(c.steps))
+	}
+	r.point("", r.n, c.file, c.status, c.duration, c.err, c.snapshot)
+}
+
+// point writes a test point, with the error and the attachments as its YAML diagnostic
+func (r *tapReporter) point(indent string, n int, name, status string, duration time.Duration, err error, attachments []string) {
+	switch status {
+	case statusPass:
+		fmt.Fprintf(r.w, "%sok %d - %s # time=%.3fs\n", indent, n, name, duration.Seconds())
+	case statusSkip:
+		fmt.Fprintf(r.w, "%sok %d - %s # SKIP\n", indent, n, name)
+	default:
+		fmt.Fprintf(r.w, "%snot ok %d - %s # time=%.3fs\n", indent, n, name, duration.Seconds())
+	}
+	if err == nil && len(attachments) == 0 {
+		return
+	}
+
+	fmt.Fprintf(r.w, "%s  ---\n", indent)
+	if err != nil {
+		fmt.Fprintf(r.w, "%s  message: %s\n", indent, strconv.Quote(message(err)))
+	}
+	fmt.Fprintf(r.w, "%s  duration_ms: %d\n", indent, duration.Milliseconds())
+	if len(attachments) > 0 {
+		fmt.Fprintf(r.w, "%s  attachments:\n", indent)
+		for _, path := range attachments {
+			fmt.Fprintf(r.w, "%s    - %s\n", indent, strconv.Quote(path))
+		}
+	}
+	fmt.Fprintf(r.w, "%s  ...\n", indent)
+}
+
+func (r *tapReporter) summary(cases []*testCase, _ time.Duration) error {
+	if r.n == 0 {
+		fmt.Fprintln(r.w, "TAP version 13")
+	}
+	n := count(cases)
+	_, err := fmt.Fprintf(r.w, "1..%d\n# passed %d\n# failed %d\n# skipped %d\n",
+		len(cases), n[statusPass], n[statusFail], n[statusSkip])
+	return err
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"time"
+
+	"github.com/go-rod/wayang"
+)
+
+// reporterCases are a passed, a failed, a skipped and a test case whose setup failed, with fixed durations
+func (s *S) reporterCases() []*testCase {
+	runner, err := s.runner.Incognito()
+	s.Nil(err)
+	defer runner.Close()
+
+	_, rErr := runner.RunProgram(program(`{
+		"steps": [
+			{ "action": "log", "message": "start" },
+			{ "action": "error", "message": "boom" },
+			{ "action": "log", "message": "end" }
+		]
+	}`))
+	s.NotNil(rErr)
+	steps := runner.Steps()
+	for i := range steps {
+		if steps[i].Status != wayang.StepSkipped {
+			steps[i].Duration = time.Duration(i+1) * 250 * time.Millisecond
+		}
+	}
+	steps[0].Screenshots = []string{"shot.png"}
+
+	passed := steps[0]
+	passed.Screenshots = nil
+
+	return []*testCase{
+		{file: "pass.json", status: statusPass, duration: 1500 * time.Millisecond, steps: []wayang.StepResult{passed}},
+		{
+			file: "fail.json", status: statusFail, duration: 1500 * time.Millisecond, steps: steps,
+			err: rErr, snapshot: []string{"failure/fail/page.png"},
+		},
+		{file: "skip.json", status: statusSkip},
+		{file: "setup.json", status: statusFail, duration: 500 * time.Millisecond, err: errors.New("setup: [no login]")},
+	}
+}
+
+func (s *S) TestJUnitReporter() {
+	cases := s.reporterCases()
+
+	type expected struct {
+		tests, failures, skipped int
+		names                    []string
+		failure                  string
+		attachments              string
+	}
+	table := []struct {
+		c        *testCase
+		expected expected
+	}{
+		{cases[0], expected{1, 0, 0, []string{"root[0].log"}, "", ""}},
+		{cases[1], expected{3, 1, 1, []string{"root[0].log", "root[1].error", "root[2].log"}, "root[1].error: [boom]",
+			"[[ATTACHMENT|failure/fail/page.png]]\n"}},
+		{cases[2], expected{1, 0, 1, []string{"program"}, "", ""}},
+		{cases[3], expected{1, 1, 0, []string{"program"}, "setup: [no login]", ""}},
+	}
+
+	for _, item := range table {
+		out := &bytes.Buffer{}
+		s.Nil((&junitReporter{out}).summary([]*testCase{item.c}, time.Second))
+		s.Contains(out.String(), xml.Header)
+
+		report := junitSuites{}
+		s.Nil(xml.Unmarshal(out.Bytes(), &report), out.String())
+		s.Len(report.Suites, 1)
+		suite := report.Suites[0]
+		s.Equal(item.c.file, suite.Name)
+		s.Equal(item.expected.tests, suite.Tests, item.c.file)
+		s.Equal(item.expected.failures, suite.Failures, item.c.file)
+		s.Equal(item.expected.skipped, suite.Skipped, item.c.file)
+		s.Equal(suite.Tests, report.Tests)
+		s.Equal(suite.Failures, report.Failures)
+		s.Equal(suite.Skipped, report.Skipped)
+
+		names := []string{}
+		for _, tc := range suite.Cases {
+			names = append(names, tc.Name)
+			s.Equal(item.c.file, tc.Classname)
+			if tc.Failure != nil {
+				s.Equal(item.expected.failure, tc.Failure.Message)
+				s.Equal(item.expected.attachments, tc.SystemOut)
+			}
+		}
+		s.Equal(item.expected.names, names)
+	}
+
+	// the screenshots of a step are its attachments
+	out := &bytes.Buffer{}
+	s.Nil((&junitReporter{out}).summary(cases, time.Second))
+	report := junitSuites{}
+	s.Nil(xml.Unmarshal(out.Bytes(), &report))
+	s.Equal("[[ATTACHMENT|shot.png]]\n", report.Suites[1].Cases[0].SystemOut)
+	s.Equal([]int{6, 2, 2}, []int{report.Tests, report.Failures, report.Skipped})
+	s.Equal("1.000", report.Time)
+}
+
+func (s *S) TestTAPReporter() {
+	cases := s.reporterCases()
+
+	table := []struct {
+		c        *testCase
+		expected string
+	}{
+		{cases[0], `TAP version 13
+    # Subtest: pass.json
+    ok 1 - root[0].log # time=0.250s
+    1..1
+ok 1 - pass.json # time=1.500s
+`},
+		{cases[1], `TAP version 13
+    # Subtest: fail.json
+    ok 1 - root[0].log # time=0.250s
+      ---
+      duration_ms: 250
+      attachments:
+        - "shot.png"
+      ...
+    not ok 2 - root[1].error # time=0.500s
+      ---
+      message: "root[1].error: [boom]"
+      duration_ms: 500
+      ...
+    ok 3 - root[2].log # SKIP
+    1..3
+not ok 1 - fail.json # time=1.500s
+  ---
+  message: "root[1].error: [boom]"
+  duration_ms: 1500
+  attachments:
+    - "failure/fail/page.png"
+  ...
+`},
+		{cases[2], `TAP version 13
+ok 1 - skip.json # SKIP
+`},
+		{cases[3], `TAP version 13
+not ok 1 - setup.json # time=0.500s
+  ---
+  message: "setup: [no login]"
+  duration_ms: 500
+  ...
+`},
+	}
+
+	for _, item := range table {
+		out := &bytes.Buffer{}
+		(&tapReporter{w: out}).result(item.c)
+		s.Equal(item.expected, out.String())
+	}
+
+	out := &bytes.Buffer{}
+	r := &tapReporter{w: out}
+	for _, c := range cases {
+		r.result(c)
+	}
+	s.Nil(r.summary(cases, time.Second))
+	s.Contains(out.String(), "\nok 3 - skip.json # SKIP\nnot ok 4 - setup.json")
+	s.True(bytes.HasSuffix(out.Bytes(), []byte("1..4\n# passed 1\n# failed 2\n# skipped 1\n")), out.String())
+	s.Equal(1, bytes.Count(out.Bytes(), []byte("TAP version 13")))
+}
+
+func (s *S) TestJSONReporter() {
+	cases := s.reporterCases()
+
+	out := &bytes.Buffer{}
+	r := &jsonReporter{out}
+	for _, c := range cases {
+		r.result(c)
+	}
+	s.Nil(r.summary(cases, 2*time.Second))
+
+	report := jsonReport{}
+	s.Nil(json.Unmarshal(out.Bytes(), &report))
+	s.Equal([]int{1, 2, 1}, []int{report.Passed, report.Failed, report.Skipped})
+	s.Equal(2.0, report.Duration)
+	s.Len(report.Programs, 4)
+
+	pass, fail, skip, setup := report.Programs[0], report.Programs[1], report.Programs[2], report.Programs[3]
+	s.Equal(jsonCase{File: "pass.json", Status: statusPass, Duration: 1.5, Steps: []jsonStep{
+		{Source: "root[0]", Action: "log", Status: statusPass, Duration: 0.25},
+	}}, pass)
+
+	s.Equal(statusFail, fail.Status)
+	s.Equal("root[1].error: [boom]", fail.Error)
+	s.Equal([]string{"failure/fail/page.png"}, fail.Snapshot)
+	s.Equal([]jsonStep{
+		{Source: "root[0]", Action: "log", Status: statusPass, Duration: 0.25, Screenshots: []string{"shot.png"}},
+		{Source: "root[1]", Action: "error", Status: statusFail, Duration: 0.5, Error: "root[1].error: [boom]"},
+		{Source: "root[2]", Action: "log", Status: statusSkip},
+	}, fail.Steps)
+
+	s.Equal(jsonCase{File: "skip.json", Status: statusSkip, Steps: []jsonStep{}}, skip)
+	s.Equal(jsonCase{File: "setup.json", Status: statusFail, Duration: 0.5, Error: "setup: [no login]", Steps: []jsonStep{}}, setup)
+}
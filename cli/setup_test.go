@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"testing"
+
+	"github.com/go-rod/rod/lib/cdp"
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/stretchr/testify/suite"
+	"github.com/ysmood/kit"
+
+	"github.com/go-rod/wayang"
+)
+
+type S struct {
+	suite.Suite
+
+	runner *wayang.Runner
+}
+
+func Test(t *testing.T) {
+	s := new(S)
+
+	url := launcher.New().Headless(true).Launch()
+	s.runner = wayang.NewRemoteRunner(cdp.New(url))
+	s.runner.Logger = log.New(&bytes.Buffer{}, "", 0)
+	defer s.runner.Close()
+
+	suite.Run(t, s)
+}
+
+func program(test string) wayang.Program {
+	program, err := wayang.ParseProgram([]byte(test), "")
+	kit.E(err)
+	return program
+}
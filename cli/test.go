@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-rod/rod/lib/cdp"
+	"github.com/go-rod/rod/lib/launcher"
+
+	"github.com/go-rod/wayang"
+)
+
+// testCase is a program file run by the test command
+type testCase struct {
+	file     string
+	program  wayang.Program
+	status   string
+	duration time.Duration
+
+	// err is the error of the program, its setup or teardown, or of reading it
+	err error
+
+	// snapshot are the paths of the failure snapshot
+	snapshot []string
+
+	// steps are the results of the steps of the program, they are empty when it didn't run
+	steps []wayang.StepResult
+}
+
+// the statuses of the test cases are the same as the ones of their steps
+const (
+	statusPass = wayang.StepPassed
+	statusFail = wayang.StepFailed
+	statusSkip = wayang.StepSkipped
+)
+
+type tester struct {
+	runner    *wayang.Runner
+	timeout   time.Duration
+	artifacts string
+	setup     *wayang.Program
+	teardown  *wayang.Program
+}
+
+// testCommand runs the program files that the arguments match as test cases, each one in a new incognito page
+func testCommand(args []string) {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	headless := flags.Bool("headless", true, "decide between whether to run chrome in windowed mode or not")
+	timeout := flags.Int("timeout", 30, "timeout for each test case with its setup in seconds, the teardown has a timeout of its own")
+	artifacts := flags.String("artifacts", "", "the directory that files written by the programs, such as screenshots, are relative to")
+	snapshots := flags.String("snapshots", "snapshots", "the directory of the baseline screenshots used by matchScreenshot")
+	update := flags.Bool("updateSnapshots", false, "overwrite the baseline screenshots instead of comparing with them")
+	setup := flags.String("setup", "", "a program that runs in the page of each test case before it")
+	teardown := flags.String("teardown", "", "a program that runs in the page of each test case after it, even when it fails")
+	tags := flags.String("tags", "", "comma separated tags, only the programs with one of them run")
+	skipTags := flags.String("skipTags", "", "comma separated tags, the programs with one of them are skipped")
+	failFast := flags.Bool("failFast", false, "skip the remaining test cases after the first failure")
+	reporterName := flags.String("reporter", "pretty", "the format of the results: pretty, json, junit or tap")
+	reportFile := flags.String("reportFile", "", "the file to write the results to instead of stdout, the pretty results are still printed")
+	device := flags.String("device", "", "the name of a device preset to emulate, it overrides the device of the programs")
+	_ = flags.Parse(args)
+
+	reporters, closeReport, err := newReporters(*reporterName, *reportFile)
+	if err != nil {
+		log.Fatal("Error while creating the reporter:", err)
+	}
+
+	patterns := flags.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"tests"}
+	}
+	files, err := discover(patterns)
+	if err != nil {
+		log.Fatal("Error while finding the test programs:", err)
+	}
+
+	t := &tester{
+		timeout:   time.Duration(*timeout) * time.Second,
+		artifacts: *artifacts,
+	}
+	if *setup != "" {
+		program, err := wayang.ReadProgram(*setup)
+		if err != nil {
+			log.Fatal("Error while reading the setup program:", err)
+		}
+		t.setup = &program
+	}
+	if *teardown != "" {
+		program, err := wayang.ReadProgram(*teardown)
+		if err != nil {
+			log.Fatal("Error while reading the teardown program:", err)
+		}
+		t.teardown = &program
+	}
+
+	cases := []*testCase{}
+	for _, file := range files {
+		c := &testCase{file: file}
+		c.program, c.err = wayang.ReadProgram(file)
+		if *device != "" {
+			c.program.Device = &wayang.Device{Name: *device}
+		}
+		if c.err == nil && !tagged(c.program.Tags, split(*tags), split(*skipTags)) {
+			c.status = statusSkip
+		}
+		cases = append(cases, c)
+	}
+
+	url := launcher.New().Headless(*headless).Launch()
+	t.runner = wayang.NewRemoteRunner(cdp.New(url))
+	t.runner.ArtifactsDir = *artifacts
+	t.runner.SnapshotsDir = *snapshots
+	t.runner.UpdateSnapshots = *update
+
+	start := time.Now()
+	failed := t.runAll(cases, *failFast, reporters)
+	t.runner.Close()
+
+	duration := time.Since(start)
+	for _, r := range reporters {
+		if err := r.summary(cases, duration); err != nil {
+			log.Fatal("Error while writing the results:", err)
+		}
+	}
+	if err := closeReport(); err != nil {
+		log.Fatal("Error while writing the results:", err)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// runAll runs the test cases in order and reports each one, it returns whether one of them failed
+func (t *tester) runAll(cases []*testCase, failFast bool, reporters []reporter) bool {
+	failed := false
+	for _, c := range cases {
+		switch {
+		case c.status != "": // filtered out by the tags
+		case failed && failFast:
+			c.status = statusSkip
+		case c.err != nil:
+			c.status = statusFail
+		default:
+			t.run(c)
+		}
+		failed = failed || c.status == statusFail
+		for _, r := range reporters {
+			r.result(c)
+		}
+	}
+	return failed
+}
+
+// run the test case in a new incognito page, with the setup before it and the teardown after it
+func (t *tester) run(c *testCase) {
+	start := time.Now()
+	defer func() { c.duration = time.Since(start) }()
+
+	runner, err := t.runner.Incognito()
+	if err != nil {
+		c.status, c.err = statusFail, err
+		return
+	}
+	defer runner.Close()
+
+	// the setup and the program share a timeout, and the teardown has its own, so that it still runs
+	// after a program that timed out
+	page := runner.P
+	ctx, cancel := context.WithTimeout(page.GetContext(), t.timeout)
+	defer cancel()
+	runner.P = page.Context(ctx, cancel)
+
+	var rErr *wayang.RuntimeError
+	if t.setup != nil {
+		if _, rErr = runner.RunProgram(*t.setup); rErr != nil {
+			c.err = fmt.Errorf("setup: %s", strings.TrimSpace(rErr.Error()))
+		}
+	}
+	if rErr == nil {
+		if _, rErr = runner.RunProgram(c.program); rErr != nil {
+			c.err = rErr
+		}
+		c.steps = runner.Steps()
+	}
+	if t.teardown != nil {
+		// the mocks of the program don't handle the requests of the teardown
+		runner.Unroute(&wayang.Route{})
+
+		ctx, cancel := context.WithTimeout(page.GetContext(), t.timeout)
+		defer cancel()
+		runner.P = page.Context(ctx, cancel)
+
+		_, teardownErr := runner.RunProgram(*t.teardown)
+		if teardownErr != nil && c.err == nil {
+			rErr = teardownErr
+			c.err = fmt.Errorf("teardown: %s", strings.TrimSpace(teardownErr.Error()))
+		}
+	}
+
+	c.status = statusPass
+	if c.err == nil {
+		return
+	}
+	c.status = statusFail
+
+	if snap := rErr.Snapshot(); snap != nil {
+		dir := filepath.Join(t.artifacts, "failure", caseName(c.file))
+		c.snapshot, err = snap.Write(dir)
+		if err != nil {
+			log.Print("Error while writing the failure snapshot:", err)
+		}
+	}
+}
+
+// discover the program files of the patterns, a pattern is either a glob or a directory that is searched recursively
+func discover(patterns []string) ([]string, error) {
+	files := []string{}
+	seen := map[string]bool{}
+	for _, pattern := range patterns {
+		matches := []string{}
+		if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+			err := filepath.Walk(pattern, func(path string, info os.FileInfo, err error) error {
+				if err == nil && !info.IsDir() && isProgramFile(path) {
+					matches = append(matches, path)
+				}
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			matches, err = filepath.Glob(pattern)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no program files match %s", pattern)
+		}
+
+		sort.Strings(matches)
+		for _, file := range matches {
+			if !seen[file] {
+				seen[file] = true
+				files = append(files, file)
+			}
+		}
+	}
+	return files, nil
+}
+
+func isProgramFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// tagged reports whether a program with the tags runs, it must have one of the included tags when there are some,
+// and none of the excluded ones
+func tagged(tags, include, exclude []string) bool {
+	has := func(list []string) bool {
+		for _, tag := range tags {
+			for _, item := range list {
+				if tag == item {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return (len(include) == 0 || has(include)) && !has(exclude)
+}
+
+func split(list string) []string {
+	res := []string{}
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
+
+// caseName is the file of the test case without its extension, usable as a directory name
+func caseName(file string) string {
+	name := strings.TrimSuffix(filepath.ToSlash(filepath.Clean(file)), filepath.Ext(file))
+	return strings.NewReplacer("/", "_", ":", "_", "..", "_").Replace(name)
+}
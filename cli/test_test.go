@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ysmood/kit"
+)
+
+func (s *S) TestDiscover() {
+	dir, err := ioutil.TempDir("", "wayang")
+	kit.E(err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	for _, name := range []string{"b.json", "a.yaml", "notes.txt", filepath.Join("sub", "c.yml")} {
+		kit.E(kit.OutputFile(filepath.Join(dir, name), "{}", nil))
+	}
+
+	files, err := discover([]string{dir})
+	s.Nil(err)
+	s.Equal([]string{
+		filepath.Join(dir, "a.yaml"),
+		filepath.Join(dir, "b.json"),
+		filepath.Join(dir, "sub", "c.yml"),
+	}, files)
+
+	// a glob, and the files that several patterns match once
+	files, err = discover([]string{filepath.Join(dir, "*.json"), dir})
+	s.Nil(err)
+	s.Equal(filepath.Join(dir, "b.json"), files[0])
+	s.Len(files, 3)
+
+	_, err = discover([]string{filepath.Join(dir, "*.xml")})
+	s.EqualError(err, "no program files match "+filepath.Join(dir, "*.xml"))
+}
+
+func (s *S) TestTagged() {
+	cases := []struct {
+		tags, include, exclude string
+		expected               bool
+	}{
+		{"", "", "", true},
+		{"smoke", "", "", true},
+		{"", "smoke", "", false},
+		{"smoke, checkout", "smoke", "", true},
+		{"checkout", "smoke,login", "", false},
+		{"smoke,slow", "smoke", "slow", false},
+		{"smoke", "", "slow", true},
+	}
+	for _, c := range cases {
+		s.Equal(c.expected, tagged(split(c.tags), split(c.include), split(c.exclude)), c)
+	}
+}
+
+func (s *S) TestCaseName() {
+	s.Equal("tests_login", caseName("tests/login.json"))
+	s.Equal("tests_login", caseName("./tests//login.json"))
+	s.Equal("__shared_checkout", caseName("../shared/checkout.yaml"))
+}
+
+func (s *S) TestFailFast() {
+	ok := program(`{ "steps": [{ "action": "log", "message": "ok" }] }`)
+	newCases := func() []*testCase {
+		return []*testCase{
+			{file: "unreadable.json", err: errors.New("unreadable")},
+			{file: "filtered.json", program: ok, status: statusSkip},
+			{file: "ok.json", program: ok},
+		}
+	}
+	t := &tester{runner: s.runner, timeout: 10 * time.Second}
+	out := &bytes.Buffer{}
+
+	cases := newCases()
+	s.True(t.runAll(cases, true, []reporter{&prettyReporter{out}}))
+	s.Equal([]string{statusFail, statusSkip, statusSkip}, []string{cases[0].status, cases[1].status, cases[2].status})
+	s.Contains(out.String(), "SKIP  ok.json")
+
+	cases = newCases()
+	s.True(t.runAll(cases, false, nil))
+	s.Equal([]string{statusFail, statusSkip, statusPass}, []string{cases[0].status, cases[1].status, cases[2].status})
+}
+
+func (s *S) TestSetupTeardown() {
+	dir, err := ioutil.TempDir("", "wayang")
+	kit.E(err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	s.runner.ArtifactsDir = dir
+	defer func() { s.runner.ArtifactsDir = "" }()
+
+	setup := program(`{ "steps": [{ "action": "eval", "expression": "() => { document.body.innerText = 'setup' }" }] }`)
+	failedSetup := program(`{ "steps": [{ "action": "error", "message": "no login" }] }`)
+
+	// the teardown checks that it runs after the setup and the program, and writes a file to show that it ran
+	teardown := program(`{
+		"steps": [
+			{
+				"action": "if",
+				"condition": {
+					"action": "textContains",
+					"expected": "setup",
+					"statement": { "action": "text", "element": "//body" }
+				},
+				"statement": { "action": "screenshot", "path": "teardown.png" },
+				"otherwise": { "action": "error", "message": "the setup didn't run first" }
+			}
+		]
+	}`)
+	t := &tester{runner: s.runner, timeout: 2 * time.Second, artifacts: dir, setup: &setup, teardown: &teardown}
+	teardownFile := filepath.Join(dir, "teardown.png")
+
+	c := &testCase{file: "ok.json", program: program(`{
+		"steps": [{ "action": "eval", "expression": "() => { document.body.innerText += ',program' }" }]
+	}`)}
+	t.run(c)
+	s.Equal(statusPass, c.status)
+	s.Nil(c.err)
+	s.FileExists(teardownFile)
+	kit.E(os.Remove(teardownFile))
+
+	// the teardown runs with a timeout of its own after a program that timed out
+	c = &testCase{file: "slow.json", program: program(`{
+		"steps": [{ "action": "assert", "statement": { "action": "has", "element": "//h1" }, "duration": 30 }]
+	}`)}
+	t.run(c)
+	s.Equal(statusFail, c.status)
+	s.Contains(c.err.Error(), "expected the statement to return true")
+	s.FileExists(teardownFile)
+	kit.E(os.Remove(teardownFile))
+
+	// the program doesn't run when the setup fails
+	t.setup = &failedSetup
+	c = &testCase{file: "ok.json", program: setup}
+	t.run(c)
+	s.Equal(statusFail, c.status)
+	s.EqualError(c.err, "setup: [no login]")
+	s.Empty(c.steps)
+	s.NoFileExists(filepath.Join(dir, "teardown.png"))
+}
+
+func (s *S) TestTeardownAfterMocks() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<html><body>served</body></html>"))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "wayang")
+	kit.E(err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	// the teardown loads the page from the server, neither from the mocks of the program nor stuck in them
+	teardown := program(`{
+		"steps": [
+			{ "action": "navigate", "link": "` + server.URL + `" },
+			{
+				"action": "if",
+				"condition": {
+					"action": "textEqual",
+					"expected": "served",
+					"statement": { "action": "text", "element": "//body" }
+				},
+				"statement": { "action": "screenshot", "path": "teardown.png" },
+				"otherwise": { "action": "error", "message": "the mocks of the program handled the teardown" }
+			}
+		]
+	}`)
+	t := &tester{runner: s.runner, timeout: 2 * time.Second, artifacts: dir, teardown: &teardown}
+
+	c := &testCase{file: "mocked.json", program: program(`{
+		"mocks": [
+			{ "url": "*", "headers": { "Content-Type": "text/html" }, "body": "<html><body>mocked</body></html>" }
+		],
+		"steps": [
+			{ "action": "navigate", "link": "` + server.URL + `" },
+			{ "action": "assert", "statement": { "action": "has", "element": "//h1" }, "duration": 30 }
+		]
+	}`)}
+	t.run(c)
+	s.Equal(statusFail, c.status)
+	s.Contains(c.err.Error(), "expected the statement to return true")
+	s.FileExists(filepath.Join(dir, "teardown.png"))
+}
@@ -0,0 +1,132 @@
+package wayang
+
+import (
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// throttling is the latency in milliseconds, and the throughputs in kilobits per second of a connection
+type throttling struct {
+	latency  float64
+	download float64
+	upload   float64
+}
+
+// throttlingPresets are the presets of the network panel of the Chrome DevTools
+var throttlingPresets = map[string]throttling{
+	"slow3g":    {latency: 2000, download: 400, upload: 400},
+	"fast3g":    {latency: 562.5, download: 1474.56, upload: 675},
+	"regular4g": {latency: 20, download: 4096, upload: 3072},
+}
+
+// networkConditions are emulated together by the protocol, so the runner keeps the ones an action doesn't change
+type networkConditions struct {
+	offline bool
+	throttling
+}
+
+func (parent *Runner) emulateNetwork() error {
+	c := parent.conditions
+	conditions := proto.NetworkEmulateNetworkConditions{
+		Offline:            c.offline,
+		Latency:            c.latency,
+		DownloadThroughput: -1,
+		UploadThroughput:   -1,
+	}
+	// the protocol takes bytes per second
+	if c.download > 0 {
+		conditions.DownloadThroughput = c.download * 1024 / 8
+	}
+	if c.upload > 0 {
+		conditions.UploadThroughput = c.upload * 1024 / 8
+	}
+	return conditions.Call(parent.P)
+}
+
+func blockURLsAction(ra runtimeAction, act Action) interface{} {
+	urls := []string{}
+	list, ok := act["urls"].([]interface{})
+	if !ok {
+		return ra.err("an 'urls' key (type []string) is required to be present")
+	}
+	for _, item := range list {
+		url, ok := item.(string)
+		if !ok {
+			return ra.err("urls must be an array of strings")
+		}
+		urls = append(urls, url)
+	}
+
+	if err := (proto.NetworkSetBlockedURLs{Urls: urls}).Call(ra.page); err != nil {
+		return ra.err("could not block the urls:", err)
+	}
+	return nil
+}
+
+func disableCacheAction(ra runtimeAction, act Action) interface{} {
+	disabled := true
+	if value, ok := act["disabled"].(bool); ok {
+		disabled = value
+	}
+
+	if err := (proto.NetworkSetCacheDisabled{CacheDisabled: disabled}).Call(ra.page); err != nil {
+		return ra.err("could not set the cache:", err)
+	}
+	return nil
+}
+
+func resetNetworkAction(ra runtimeAction, _ Action) interface{} {
+	run := ra.runner
+	run.conditions = networkConditions{}
+
+	err := run.emulateNetwork()
+	if err == nil {
+		err = proto.NetworkSetBlockedURLs{Urls: []string{}}.Call(ra.page)
+	}
+	if err == nil {
+		err = proto.NetworkSetCacheDisabled{CacheDisabled: false}.Call(ra.page)
+	}
+	if err != nil {
+		return ra.err("could not reset the network:", err)
+	}
+	return nil
+}
+
+func setOfflineAction(ra runtimeAction, act Action) interface{} {
+	run := ra.runner
+
+	run.conditions.offline = true
+	if value, ok := act["offline"].(bool); ok {
+		run.conditions.offline = value
+	}
+
+	if err := run.emulateNetwork(); err != nil {
+		return ra.err("could not set the network offline:", err)
+	}
+	return nil
+}
+
+func throttleAction(ra runtimeAction, act Action) interface{} {
+	run := ra.runner
+
+	t := throttling{}
+	if name, ok := act["preset"].(string); ok && name != "none" {
+		if t, ok = throttlingPresets[name]; !ok {
+			return ra.err("preset must be none, slow3g, fast3g or regular4g")
+		}
+	}
+	if latency, ok := act["latency"].(float64); ok {
+		t.latency = latency
+	}
+	if download, ok := act["download"].(float64); ok {
+		t.download = download
+	}
+	if upload, ok := act["upload"].(float64); ok {
+		t.upload = upload
+	}
+
+	run.conditions.throttling = t
+	if err := run.emulateNetwork(); err != nil {
+		return ra.err("could not throttle the network:", err)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package wayang
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// cookieFilter selects cookies by their name, domain and path, empty fields match every cookie.
+// The domain also matches the cookies of its subdomains.
+type cookieFilter struct {
+	Name   string
+	Domain string
+	Path   string
+}
+
+func (f cookieFilter) match(cookie *proto.NetworkCookie) bool {
+	if f.Name != "" && cookie.Name != f.Name {
+		return false
+	}
+	if f.Path != "" && cookie.Path != f.Path {
+		return false
+	}
+	if f.Domain != "" {
+		domain := strings.TrimPrefix(f.Domain, ".")
+		cookieDomain := strings.TrimPrefix(cookie.Domain, ".")
+		if cookieDomain != domain && !strings.HasSuffix(cookieDomain, "."+domain) {
+			return false
+		}
+	}
+	return true
+}
+
+// cookies returns the cookies of the browser that match the filter
+func (parent *Runner) cookies(filter cookieFilter) ([]*proto.NetworkCookie, error) {
+	res, err := proto.NetworkGetAllCookies{}.Call(parent.P)
+	if err != nil {
+		return nil, err
+	}
+
+	list := []*proto.NetworkCookie{}
+	for _, cookie := range res.Cookies {
+		if filter.match(cookie) {
+			list = append(list, cookie)
+		}
+	}
+	return list, nil
+}
+
+// setCookies sets the cookies, the ones without a url or domain belong to the current page
+func (parent *Runner) setCookies(cookies []*proto.NetworkCookieParam) error {
+	current := ""
+	for _, cookie := range cookies {
+		if cookie.URL != "" || cookie.Domain != "" {
+			continue
+		}
+		if current == "" {
+			info, err := proto.TargetGetTargetInfo{TargetID: parent.P.TargetID}.Call(parent.B)
+			if err != nil {
+				return err
+			}
+			current = info.TargetInfo.URL
+		}
+		cookie.URL = current
+	}
+
+	return proto.NetworkSetCookies{Cookies: cookies}.Call(parent.P)
+}
+
+// cookieParams converts the cookies of an action, or the ones returned by getCookies, to the parameters of
+// Network.setCookies. A cookie that expires at the end of the session has no expiry.
+func cookieParams(list interface{}) ([]*proto.NetworkCookieParam, error) {
+	items := []map[string]interface{}{}
+	bin, err := json.Marshal(list)
+	if err == nil {
+		err = json.Unmarshal(bin, &items)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cookies := []*proto.NetworkCookieParam{}
+	for _, item := range items {
+		if expires, ok := item["expires"].(float64); ok && expires <= 0 || item["session"] == true {
+			delete(item, "expires")
+		}
+
+		cookie := &proto.NetworkCookieParam{}
+		bin, err := json.Marshal(item)
+		if err == nil {
+			err = json.Unmarshal(bin, cookie)
+		}
+		if err != nil {
+			return nil, err
+		}
+		cookies = append(cookies, cookie)
+	}
+	return cookies, nil
+}
+
+func clearCookiesAction(ra runtimeAction, _ Action) interface{} {
+	if err := (proto.NetworkClearBrowserCookies{}).Call(ra.page); err != nil {
+		return ra.err("could not clear the cookies:", err)
+	}
+	return nil
+}
+
+func deleteCookiesAction(ra runtimeAction, act Action) interface{} {
+	filter, rErr := ra.cookieFilter(act)
+	if rErr != nil {
+		return *rErr
+	}
+
+	cookies, err := ra.runner.cookies(filter)
+	if err != nil {
+		return ra.err("could not get the cookies:", err)
+	}
+
+	for _, cookie := range cookies {
+		err := proto.NetworkDeleteCookies{Name: cookie.Name, Domain: cookie.Domain, Path: cookie.Path}.Call(ra.page)
+		if err != nil {
+			return ra.err("could not delete the cookie "+cookie.Name+":", err)
+		}
+	}
+	return float64(len(cookies))
+}
+
+func getCookiesAction(ra runtimeAction, act Action) interface{} {
+	filter, rErr := ra.cookieFilter(act)
+	if rErr != nil {
+		return *rErr
+	}
+
+	cookies, err := ra.runner.cookies(filter)
+	if err != nil {
+		return ra.err("could not get the cookies:", err)
+	}
+
+	res := []interface{}{}
+	bin, err := json.Marshal(cookies)
+	if err == nil {
+		err = json.Unmarshal(bin, &res)
+	}
+	if err != nil {
+		return ra.err("could not convert the cookies:", err)
+	}
+	return res
+}
+
+func setCookiesAction(ra runtimeAction, act Action) interface{} {
+	list, ok := act["cookies"].([]interface{})
+	if !ok {
+		return ra.err("cookies must be an array of cookies")
+	}
+
+	cookies, err := cookieParams(list)
+	if err != nil {
+		return ra.err("could not parse the cookies:", err)
+	}
+
+	if err := ra.runner.setCookies(cookies); err != nil {
+		return ra.err("could not set the cookies:", err)
+	}
+	return nil
+}
+
+func (ra runtimeAction) cookieFilter(act Action) (cookieFilter, *RuntimeError) {
+	filter := cookieFilter{}
+	for key, field := range map[string]*string{"name": &filter.Name, "domain": &filter.Domain, "path": &filter.Path} {
+		if value, ok := act[key]; ok {
+			*field, ok = value.(string)
+			if !ok {
+				err := ra.err(key + " must be a string")
+				return filter, &err
+			}
+		}
+	}
+	return filter, nil
+}
@@ -0,0 +1,228 @@
+package wayang
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/cdp"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// Dialogs is how the alert, confirm, prompt and beforeunload dialogs of a program are handled
+// when no handleDialog action handles them.
+type Dialogs struct {
+	// Handle is accept, dismiss or none, which leaves the dialogs open for the handleDialog action.
+	// The dialogs are dismissed when it is empty.
+	Handle string `json:"handle"`
+
+	// PromptText is the text that accepted prompts return, their default value when it is empty
+	PromptText string `json:"promptText"`
+}
+
+// dialogLog records the dialogs of the page while a program runs, and handles them
+type dialogLog struct {
+	lock    sync.Mutex
+	dialogs []*dialog
+
+	// the page that the dialogs are recorded on, they are handled in their own goroutines while the actions run
+	page *rod.Page
+
+	// the handling of the next dialog, which takes precedence over the policy of the program
+	next *Dialogs
+
+	// closed and replaced every time the log changes
+	changed chan struct{}
+}
+
+type dialog struct {
+	*proto.PageJavascriptDialogOpening
+	handled bool
+}
+
+func newDialogLog() *dialogLog {
+	return &dialogLog{changed: make(chan struct{})}
+}
+
+func (d *Dialogs) valid() bool {
+	switch d.Handle {
+	case "", "accept", "dismiss", "none":
+		return true
+	}
+	return false
+}
+
+// record the dialogs of the page until the returned function is called
+func (l *dialogLog) record(parent *Runner) (stop func()) {
+	l.page = parent.basePage()
+	return parent.eachPageEvent([]proto.Payload{&proto.PageEnable{}}, func(e *cdp.Event) {
+		opening := &proto.PageJavascriptDialogOpening{}
+		if !rod.Event(e, opening) {
+			return
+		}
+
+		l.lock.Lock()
+		defer l.lock.Unlock()
+
+		item := &dialog{PageJavascriptDialogOpening: opening}
+		l.dialogs = append(l.dialogs, item)
+
+		policy := parent.program.Dialogs
+		if l.next != nil {
+			policy, l.next = l.next, nil
+		}
+		if policy == nil {
+			policy = &Dialogs{}
+		}
+		if policy.Handle != "none" {
+			item.handled = true
+			go func() {
+				if err := item.handle(l.page, policy); err != nil {
+					parent.Error("could not handle the dialog " + item.Message + ": " + err.Error())
+				}
+			}()
+		}
+
+		l.notify()
+	})
+}
+
+func (l *dialogLog) notify() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+func (l *dialogLog) len() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return len(l.dialogs)
+}
+
+func (item *dialog) handle(page *rod.Page, how *Dialogs) error {
+	text := how.PromptText
+	if text == "" {
+		text = item.DefaultPrompt
+	}
+	return proto.PageHandleJavaScriptDialog{
+		Accept:     how.Handle == "accept",
+		PromptText: text,
+	}.Call(page)
+}
+
+func (item *dialog) result() map[string]interface{} {
+	return map[string]interface{}{
+		"type":          string(item.Type),
+		"message":       item.Message,
+		"defaultPrompt": item.DefaultPrompt,
+		"url":           item.URL,
+	}
+}
+
+func handleDialogAction(ra runtimeAction, act Action) interface{} {
+	run := ra.runner
+
+	how := &Dialogs{Handle: "accept"}
+	if accept, ok := act["accept"].(bool); ok && !accept {
+		how.Handle = "dismiss"
+	}
+	how.PromptText, _ = act["promptText"].(string)
+
+	stmt := run.makeAction(act["statement"])
+	if stmt != nil {
+		run.dialogs.lock.Lock()
+		run.dialogs.next = how
+		run.dialogs.lock.Unlock()
+		defer func() {
+			run.dialogs.lock.Lock()
+			if run.dialogs.next == how {
+				run.dialogs.next = nil
+			}
+			run.dialogs.lock.Unlock()
+		}()
+	}
+
+	// with a statement the dialog that it opens is handled when it opens, otherwise the first open one is
+	item, err := ra.waitDialog(act, func(list []*dialog, from int) *dialog {
+		if stmt != nil {
+			if from < len(list) {
+				return list[from]
+			}
+			return nil
+		}
+		for _, item := range list {
+			if !item.handled {
+				item.handled = true
+				return item
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return *err
+	}
+
+	if stmt == nil {
+		if e := item.handle(run.dialogs.page, how); e != nil {
+			return ra.err("could not handle the dialog:", e)
+		}
+	}
+	return item.result()
+}
+
+func waitDialogAction(ra runtimeAction, act Action) interface{} {
+	item, err := ra.waitDialog(act, func(list []*dialog, from int) *dialog {
+		switch {
+		case from < 0 && len(list) > 0:
+			return list[len(list)-1]
+		case from >= 0 && from < len(list):
+			return list[from]
+		}
+		return nil
+	})
+	if err != nil {
+		return *err
+	}
+	return item.result()
+}
+
+// waitDialog runs the statement of the action, and waits until pick returns a dialog. The from index is where the
+// dialogs opened after the statement started begin, and it is -1 when the action has no statement.
+func (ra runtimeAction) waitDialog(act Action, pick func(list []*dialog, from int) *dialog) (*dialog, *RuntimeError) {
+	run := ra.runner
+	log := run.dialogs
+
+	from := -1
+	if stmt := run.makeAction(act["statement"]); stmt != nil {
+		from = log.len()
+		if res, ok := ra.runAction(*stmt, ra.source).(RuntimeError); ok {
+			return nil, &res
+		}
+	}
+
+	var timeout <-chan time.Time
+	if duration, ok := act["duration"].(float64); ok {
+		timeout = time.After(time.Duration(float64(time.Second) * duration))
+	}
+	ctx := ra.page.GetContext()
+
+	for {
+		log.lock.Lock()
+		item := pick(log.dialogs, from)
+		changed := log.changed
+		log.lock.Unlock()
+
+		if item != nil {
+			return item, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			rErr := ra.err("context error:", ctx.Err())
+			return nil, &rErr
+		case <-timeout:
+			rErr := ra.err("waited too long for a dialog")
+			return nil, &rErr
+		case <-changed:
+		}
+	}
+}
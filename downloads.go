@@ -0,0 +1,240 @@
+package wayang
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/cdp"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// downloadWillBegin is the Page.downloadWillBegin event with the file name that the browser suggests from the
+// Content-Disposition header or the url, which the proto package doesn't have
+type downloadWillBegin struct {
+	proto.PageDownloadWillBegin
+	SuggestedFilename string `json:"suggestedFilename"`
+}
+
+var hashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+}
+
+func waitDownloadAction(ra runtimeAction, act Action) interface{} {
+	run := ra.runner
+
+	algorithm, _ := act["hash"].(string)
+	newHash, hashed := hashes[algorithm]
+	if _, ok := act["hash"]; ok && !hashed {
+		return ra.err("hash must be md5, sha1 or sha256")
+	}
+
+	dir, _ := act["dir"].(string)
+	dir, err := run.downloadDir(dir)
+	if err != nil {
+		return ra.err("could not create the download directory:", err)
+	}
+
+	events := make(chan interface{}, 16)
+	send := func(e interface{}) {
+		select {
+		case events <- e:
+		default: // only the first downloads matter, the others are dropped instead of blocking the listener
+		}
+	}
+	stop := run.eachPageEvent([]proto.Payload{&proto.PageEnable{}}, func(e *cdp.Event) {
+		willBegin := &downloadWillBegin{}
+		update := &proto.PageDownloadProgress{}
+		switch {
+		case rod.Event(e, willBegin):
+			send(willBegin)
+		case rod.Event(e, update):
+			if update.State != proto.PageDownloadProgressStateInProgress {
+				send(update)
+			}
+		}
+	})
+	defer stop()
+
+	// downloads are saved with their guid as the name, and renamed once completed
+	err = proto.BrowserSetDownloadBehavior{
+		Behavior:         proto.BrowserSetDownloadBehaviorBehaviorAllowAndName,
+		BrowserContextID: run.B.BrowserContextID,
+		DownloadPath:     dir,
+	}.Call(run.B)
+	if err != nil {
+		return ra.err("could not set the download behavior:", err)
+	}
+	defer func() {
+		_ = proto.BrowserSetDownloadBehavior{
+			Behavior:         proto.BrowserSetDownloadBehaviorBehaviorDefault,
+			BrowserContextID: run.B.BrowserContextID,
+		}.Call(run.B)
+	}()
+
+	if stmt := run.makeAction(act["statement"]); stmt != nil {
+		if res, ok := ra.runAction(*stmt, ra.source).(RuntimeError); ok {
+			return res
+		}
+	}
+
+	var timeout <-chan time.Time
+	if duration, ok := act["duration"].(float64); ok {
+		timeout = time.After(time.Duration(float64(time.Second) * duration))
+	}
+	ctx := ra.page.GetContext()
+
+	// the first download that begins is used
+	var download *downloadWillBegin
+	for completed := false; !completed; {
+		select {
+		case <-ctx.Done():
+			return ra.err("context error:", ctx.Err())
+		case <-timeout:
+			return ra.err("waited too long for the download")
+		case e := <-events:
+			switch e := e.(type) {
+			case *downloadWillBegin:
+				if download == nil {
+					download = e
+				}
+			case *proto.PageDownloadProgress:
+				if download == nil || e.GUID != download.GUID {
+					break
+				}
+				if e.State == proto.PageDownloadProgressStateCanceled {
+					return ra.err("the download of " + download.URL + " was canceled")
+				}
+				completed = true
+			}
+		}
+	}
+
+	filename, _ := act["filename"].(string)
+	if filename == "" {
+		filename = run.downloadName(download)
+	}
+	file, err := uniqueFile(dir, filename)
+	if err != nil {
+		return ra.err("could not name the download:", err)
+	}
+	filename = filepath.Base(file)
+	if err := os.Rename(filepath.Join(dir, download.GUID), file); err != nil {
+		return ra.err("could not rename the download:", err)
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return ra.err("could not read the download:", err)
+	}
+	res := map[string]interface{}{
+		"path":     file,
+		"filename": filename,
+		"size":     float64(info.Size()),
+		"url":      download.URL,
+	}
+
+	if hashed {
+		sum, err := fileHash(file, newHash())
+		if err != nil {
+			return ra.err("could not hash the download:", err)
+		}
+		res["hash"] = sum
+	}
+	return res
+}
+
+// downloadDir returns the absolute path of the directory that downloads are saved to, which is the dir relative to
+// the artifacts directory, or a "downloads" directory inside it. Without either a temporary directory is used.
+func (parent *Runner) downloadDir(dir string) (string, error) {
+	var err error
+	switch {
+	case dir != "":
+		dir = parent.artifact(dir)
+	case parent.ArtifactsDir != "":
+		dir = parent.artifact("downloads")
+	default:
+		dir, err = ioutil.TempDir("", "wayang-downloads")
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return filepath.Abs(dir)
+}
+
+// downloadName is the file name that the browser suggests, or else the one of the Content-Disposition header of the
+// response, or the last segment of the url path, or the guid of the download when it has none
+func (parent *Runner) downloadName(download *downloadWillBegin) string {
+	if name := fileName(download.SuggestedFilename); name != "" {
+		return name
+	}
+	if name := fileName(parent.network.disposition(download.URL)); name != "" {
+		return name
+	}
+	if u, err := url.Parse(download.URL); err == nil {
+		if name := fileName(path.Base(u.Path)); name != "" {
+			return name
+		}
+	}
+	return download.GUID
+}
+
+// fileName is the last element of the name, which is empty when it has none
+func fileName(name string) string {
+	name = filepath.Base(filepath.FromSlash(name))
+	if name == "." || name == string(filepath.Separator) {
+		return ""
+	}
+	return name
+}
+
+// uniqueFile returns the path of the name in the directory, with a number added to the name, as in "report (1).csv",
+// when a file already has it, so that an earlier download isn't replaced
+func uniqueFile(dir, name string) (string, error) {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 0; ; i++ {
+		file := filepath.Join(dir, name)
+		if i > 0 {
+			file = filepath.Join(dir, fmt.Sprintf("%s (%d)%s", base, i, ext))
+		}
+		_, err := os.Stat(file)
+		if os.IsNotExist(err) {
+			return file, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+func fileHash(file string, h hash.Hash) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,228 @@
+package wayang
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// Device is the screen and user agent that a page emulates. The fields that are set override the preset of the name.
+// In a program it is either an object, or the name of a preset.
+type Device struct {
+	Name              string  `json:"name"`
+	Width             int64   `json:"width"`
+	Height            int64   `json:"height"`
+	DeviceScaleFactor float64 `json:"deviceScaleFactor"`
+	Mobile            *bool   `json:"mobile"`
+	Touch             *bool   `json:"touch"`
+	Landscape         bool    `json:"landscape"`
+	UserAgent         string  `json:"userAgent"`
+}
+
+const (
+	iOS11UA  = "Mozilla/5.0 (iPhone; CPU iPhone OS 11_0 like Mac OS X) AppleWebKit/604.1.38 (KHTML, like Gecko) Version/11.0 Mobile/15A372 Safari/604.1"
+	iOS13UA  = "Mozilla/5.0 (iPhone; CPU iPhone OS 13_7 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/13.1 Mobile/15E148 Safari/604.1"
+	iOS14UA  = "Mozilla/5.0 (iPhone; CPU iPhone OS 14_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.0.3 Mobile/15E148 Safari/604.1"
+	iPadUA   = "Mozilla/5.0 (iPad; CPU OS 11_0 like Mac OS X) AppleWebKit/604.1.34 (KHTML, like Gecko) Version/11.0 Mobile/15A5341f Safari/604.1"
+	pixel2UA = "Mozilla/5.0 (Linux; Android 8.0; Pixel 2 Build/OPD3.170816.012) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/75.0.3765.0 Mobile Safari/537.36"
+	pixel5UA = "Mozilla/5.0 (Linux; Android 11; Pixel 5) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/90.0.4430.91 Mobile Safari/537.36"
+	galaxyUA = "Mozilla/5.0 (Linux; Android 8.0.0; SM-G965U Build/R16NW) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/63.0.3239.111 Mobile Safari/537.36"
+)
+
+// on is the value of the flags of the presets that are set
+var on = true
+
+// devices are the presets of Device, rod doesn't ship a list of devices
+var devices = map[string]Device{
+	"iPhone 8":      {Width: 375, Height: 667, DeviceScaleFactor: 2, Mobile: &on, Touch: &on, UserAgent: iOS11UA},
+	"iPhone 8 Plus": {Width: 414, Height: 736, DeviceScaleFactor: 3, Mobile: &on, Touch: &on, UserAgent: iOS11UA},
+	"iPhone X":      {Width: 375, Height: 812, DeviceScaleFactor: 3, Mobile: &on, Touch: &on, UserAgent: iOS11UA},
+	"iPhone 11":     {Width: 414, Height: 828, DeviceScaleFactor: 2, Mobile: &on, Touch: &on, UserAgent: iOS13UA},
+	"iPhone 12":     {Width: 390, Height: 844, DeviceScaleFactor: 3, Mobile: &on, Touch: &on, UserAgent: iOS14UA},
+	"iPad":          {Width: 768, Height: 1024, DeviceScaleFactor: 2, Mobile: &on, Touch: &on, UserAgent: iPadUA},
+	"iPad Pro":      {Width: 1024, Height: 1366, DeviceScaleFactor: 2, Mobile: &on, Touch: &on, UserAgent: iPadUA},
+	"Pixel 2":       {Width: 411, Height: 731, DeviceScaleFactor: 2.625, Mobile: &on, Touch: &on, UserAgent: pixel2UA},
+	"Pixel 5":       {Width: 393, Height: 851, DeviceScaleFactor: 2.75, Mobile: &on, Touch: &on, UserAgent: pixel5UA},
+	"Galaxy S9+":    {Width: 320, Height: 658, DeviceScaleFactor: 4.5, Mobile: &on, Touch: &on, UserAgent: galaxyUA},
+	"Laptop":        {Width: 1366, Height: 768, DeviceScaleFactor: 1},
+	"Desktop":       {Width: 1920, Height: 1080, DeviceScaleFactor: 1},
+}
+
+// UnmarshalJSON accepts the name of a preset as well as an object
+func (d *Device) UnmarshalJSON(bin []byte) error {
+	var name string
+	if err := json.Unmarshal(bin, &name); err == nil {
+		*d = Device{Name: name}
+		return nil
+	}
+
+	type device Device
+	return json.Unmarshal(bin, (*device)(d))
+}
+
+// resolve merges the device with its preset
+func (d Device) resolve() (Device, error) {
+	res := Device{}
+	if d.Name != "" {
+		preset, ok := lookupDevice(d.Name)
+		if !ok {
+			return res, &deviceError{d.Name}
+		}
+		res = preset
+	}
+
+	res.Name = d.Name
+	if d.Width != 0 {
+		res.Width = d.Width
+	}
+	if d.Height != 0 {
+		res.Height = d.Height
+	}
+	if d.DeviceScaleFactor != 0 {
+		res.DeviceScaleFactor = d.DeviceScaleFactor
+	}
+	if d.UserAgent != "" {
+		res.UserAgent = d.UserAgent
+	}
+	// the flags are pointers, so that a false one turns off the flag of the preset
+	if d.Mobile != nil {
+		res.Mobile = d.Mobile
+	}
+	if d.Touch != nil {
+		res.Touch = d.Touch
+	}
+	if d.Landscape {
+		res.Width, res.Height = res.Height, res.Width
+	}
+	return res, nil
+}
+
+func lookupDevice(name string) (Device, bool) {
+	for key, device := range devices {
+		if strings.EqualFold(key, name) {
+			return device, true
+		}
+	}
+	return Device{}, false
+}
+
+type deviceError struct {
+	name string
+}
+
+func (e *deviceError) Error() string {
+	names := []string{}
+	for name := range devices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return "unknown device " + e.name + ", the devices are: " + strings.Join(names, ", ")
+}
+
+// Emulate makes the page emulate the device, an empty device clears the emulation
+func (parent *Runner) Emulate(device Device) error {
+	if device == (Device{}) {
+		return parent.clearDevice()
+	}
+
+	d, err := device.resolve()
+	if err != nil {
+		return err
+	}
+
+	if d.Width != 0 && d.Height != 0 {
+		scale := d.DeviceScaleFactor
+		if scale == 0 {
+			scale = 1
+		}
+		err := parent.P.ViewportE(&proto.EmulationSetDeviceMetricsOverride{
+			Width:             d.Width,
+			Height:            d.Height,
+			DeviceScaleFactor: scale,
+			Mobile:            d.Mobile != nil && *d.Mobile,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	touch := proto.EmulationSetTouchEmulationEnabled{Enabled: d.Touch != nil && *d.Touch}
+	if touch.Enabled {
+		touch.MaxTouchPoints = 5
+	}
+	if err := touch.Call(parent.P); err != nil {
+		return err
+	}
+
+	parent.emulated.userAgent = d.UserAgent
+	return parent.overrideUserAgent()
+}
+
+func (parent *Runner) clearDevice() error {
+	if err := (proto.EmulationClearDeviceMetricsOverride{}).Call(parent.P); err != nil {
+		return err
+	}
+	if err := (proto.EmulationSetTouchEmulationEnabled{Enabled: false}).Call(parent.P); err != nil {
+		return err
+	}
+	parent.emulated.userAgent = ""
+	return parent.overrideUserAgent()
+}
+
+// overrideUserAgent sets the user agent of the device and the language of the locale together,
+// since the protocol sets both with the same method
+func (parent *Runner) overrideUserAgent() error {
+	override := proto.EmulationSetUserAgentOverride{
+		UserAgent:      parent.emulated.userAgent,
+		AcceptLanguage: parent.emulated.locale,
+	}
+	if override.UserAgent == "" && override.AcceptLanguage != "" {
+		version, err := proto.BrowserGetVersion{}.Call(parent.B)
+		if err != nil {
+			return err
+		}
+		override.UserAgent = version.UserAgent
+	}
+	return override.Call(parent.P)
+}
+
+func emulateAction(ra runtimeAction, act Action) interface{} {
+	run := ra.runner
+
+	if reset, ok := act["reset"].(bool); ok && reset {
+		err := run.clearDevice()
+		if err == nil {
+			err = run.clearEnvironment()
+		}
+		if err != nil {
+			return ra.err("could not clear the emulation:", err)
+		}
+		return nil
+	}
+
+	device := Device{}
+	env := Environment{}
+	bin, err := json.Marshal(act)
+	if err == nil {
+		err = json.Unmarshal(bin, &device)
+	}
+	if err == nil {
+		err = json.Unmarshal(bin, &env)
+	}
+	if err != nil {
+		return ra.err("could not parse the emulation:", err)
+	}
+	device.Name, _ = act["device"].(string)
+
+	if device != (Device{}) {
+		if err := run.Emulate(device); err != nil {
+			return ra.err("could not emulate the device:", err)
+		}
+	}
+	if err := run.SetEnvironment(env); err != nil {
+		return ra.err("could not emulate the environment:", err)
+	}
+	return nil
+}
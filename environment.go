@@ -0,0 +1,142 @@
+package wayang
+
+import (
+	"errors"
+
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// Environment is the location, time zone, language and media preferences that a page emulates.
+// The empty fields are left as they are.
+type Environment struct {
+	Geolocation *Geolocation `json:"geolocation"`
+
+	// Timezone is an ICU time zone ID, such as Europe/Berlin
+	Timezone string `json:"timezone"`
+
+	// Locale is a language tag such as de-DE, it sets navigator.language, the Accept-Language header and Intl formats
+	Locale string `json:"locale"`
+
+	// ColorScheme is light, dark or no-preference
+	ColorScheme string `json:"colorScheme"`
+
+	// ReducedMotion is reduce or no-preference
+	ReducedMotion string `json:"reducedMotion"`
+}
+
+type Geolocation struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Accuracy  float64 `json:"accuracy"`
+}
+
+// emulated is the part of the emulation that the protocol sets together with other settings
+type emulated struct {
+	userAgent string
+	locale    string
+	media     map[string]string
+}
+
+// SetEnvironment makes the page emulate the environment
+func (parent *Runner) SetEnvironment(env Environment) error {
+	if err := env.validate(); err != nil {
+		return err
+	}
+
+	if g := env.Geolocation; g != nil {
+		err := proto.BrowserGrantPermissions{
+			Permissions:      []proto.BrowserPermissionType{proto.BrowserPermissionTypeGeolocation},
+			BrowserContextID: parent.B.BrowserContextID,
+		}.Call(parent.B)
+		if err != nil {
+			return err
+		}
+
+		accuracy := g.Accuracy
+		if accuracy == 0 {
+			accuracy = 1
+		}
+		// the fields of proto.EmulationSetGeolocationOverride are omitted when they are 0, which is a valid coordinate
+		params := map[string]float64{"latitude": g.Latitude, "longitude": g.Longitude, "accuracy": accuracy}
+		if err := proto.Call("Emulation.setGeolocationOverride", params, nil, parent.P); err != nil {
+			return err
+		}
+	}
+
+	if env.Timezone != "" {
+		if err := (proto.EmulationSetTimezoneOverride{TimezoneID: env.Timezone}).Call(parent.P); err != nil {
+			return err
+		}
+	}
+
+	if env.Locale != "" {
+		if err := (proto.EmulationSetLocaleOverride{Locale: env.Locale}).Call(parent.P); err != nil {
+			return err
+		}
+		parent.emulated.locale = env.Locale
+		if err := parent.overrideUserAgent(); err != nil {
+			return err
+		}
+	}
+
+	if env.ColorScheme != "" || env.ReducedMotion != "" {
+		if parent.emulated.media == nil {
+			parent.emulated.media = map[string]string{}
+		}
+		if env.ColorScheme != "" {
+			parent.emulated.media["prefers-color-scheme"] = env.ColorScheme
+		}
+		if env.ReducedMotion != "" {
+			parent.emulated.media["prefers-reduced-motion"] = env.ReducedMotion
+		}
+		if err := parent.emulateMedia(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (env Environment) validate() error {
+	switch env.ColorScheme {
+	case "", "light", "dark", "no-preference":
+	default:
+		return errors.New("colorScheme must be light, dark or no-preference")
+	}
+	switch env.ReducedMotion {
+	case "", "reduce", "no-preference":
+	default:
+		return errors.New("reducedMotion must be reduce or no-preference")
+	}
+	return nil
+}
+
+func (parent *Runner) emulateMedia() error {
+	features := []*proto.EmulationMediaFeature{}
+	for name, value := range parent.emulated.media {
+		features = append(features, &proto.EmulationMediaFeature{Name: name, Value: value})
+	}
+	return proto.EmulationSetEmulatedMedia{Features: features}.Call(parent.P)
+}
+
+func (parent *Runner) clearEnvironment() error {
+	if err := (proto.BrowserResetPermissions{BrowserContextID: parent.B.BrowserContextID}).Call(parent.B); err != nil {
+		return err
+	}
+	if err := (proto.EmulationClearGeolocationOverride{}).Call(parent.P); err != nil {
+		return err
+	}
+	if err := (proto.EmulationSetTimezoneOverride{}).Call(parent.P); err != nil {
+		return err
+	}
+	if err := (proto.EmulationSetLocaleOverride{}).Call(parent.P); err != nil {
+		return err
+	}
+
+	parent.emulated.locale = ""
+	parent.emulated.media = nil
+	if err := parent.overrideUserAgent(); err != nil {
+		return err
+	}
+	return parent.emulateMedia()
+}
@@ -0,0 +1,53 @@
+package wayang
+
+import (
+	"context"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/cdp"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// eachEvent calls fn with the events of the sessions that the filter accepts, until the returned function
+// is called. The domains are enabled on the page of the runner, and recovered when it stops.
+// Unlike the EachEvent of rod, it doesn't receive the events of every session.
+func (parent *Runner) eachEvent(filter func(session string) bool, domains []proto.Payload, fn func(e *cdp.Event)) (stop func()) {
+	ctx, cancel := context.WithCancel(parent.B.GetContext())
+	events := parent.B.Event().Subscribe(ctx)
+
+	page := parent.basePage()
+	recovers := []func(){}
+	for _, domain := range domains {
+		recovers = append(recovers, page.EnableDomain(domain))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for msg := range events {
+			if e, ok := msg.(*cdp.Event); ok && filter(e.SessionID) {
+				fn(e)
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+		for _, recover := range recovers {
+			recover()
+		}
+	}
+}
+
+// eachPageEvent is eachEvent that only receives the events of the page of the runner
+func (parent *Runner) eachPageEvent(domains []proto.Payload, fn func(e *cdp.Event)) (stop func()) {
+	session := string(parent.P.SessionID)
+	return parent.eachEvent(func(id string) bool { return id == session }, domains, fn)
+}
+
+// basePage is the page of the runner bound to the context of the browser instead of the one of the page, for the
+// handlers that outlive a page that times out, such as the router of a test case whose teardown runs after it
+func (parent *Runner) basePage() *rod.Page {
+	return parent.P.Context(parent.B.GetContext(), func() {})
+}
@@ -6,4 +6,5 @@ require (
 	github.com/go-rod/rod v0.41.0
 	github.com/stretchr/testify v1.6.1
 	github.com/ysmood/kit v0.24.4
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
 )
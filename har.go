@@ -0,0 +1,562 @@
+package wayang
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/cdp"
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/ysmood/kit"
+)
+
+// HAR is an HTTP Archive 1.2 document, see http://www.softwareishard.com/blog/har-12-spec/
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HARResponse struct {
+	Status      int64          `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// HARTimings are in milliseconds, -1 means the timing does not apply to the request
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// harRecorder records the requests of every page of the browser, pages opened while it records included
+type harRecorder struct {
+	runner *Runner
+	stop   func()
+	bodies sync.WaitGroup
+
+	lock     sync.Mutex
+	sessions map[string]proto.Caller
+	targets  map[proto.TargetTargetID]bool
+	entries  []*harRecord
+	byID     map[string]*harRecord
+}
+
+// harSession is a session that the browser attached to a popup of a recorded page, for which rod has no page
+type harSession struct {
+	browser *rod.Browser
+	id      string
+}
+
+func (s harSession) CallContext() (context.Context, proto.Client, string) {
+	return s.browser.GetContext(), s.browser, s.id
+}
+
+type harRecord struct {
+	request  *proto.NetworkRequestWillBeSent
+	response *proto.NetworkResponse
+	finished *proto.MonotonicTime
+	size     float64
+	failure  string
+	body     string
+	base64   bool
+}
+
+// RecordHAR starts recording the requests of every page of the browser, until StopHAR or the runner closes.
+// The records are read with HAR or SaveHAR.
+func (parent *Runner) RecordHAR() {
+	if parent.har != nil {
+		return
+	}
+
+	h := &harRecorder{
+		runner:   parent,
+		sessions: map[string]proto.Caller{},
+		targets:  map[proto.TargetTargetID]bool{},
+		byID:     map[string]*harRecord{},
+	}
+	parent.har = h
+
+	stopEvents := parent.eachEvent(func(string) bool { return true }, nil, h.handle)
+	recoverTargets := parent.B.EnableDomain(parent.B.GetContext(), "", &proto.TargetSetDiscoverTargets{Discover: true})
+
+	h.attach(parent.basePage(), string(parent.P.SessionID), parent.P.TargetID, false)
+	if pages, err := parent.B.PagesE(); err == nil {
+		for _, page := range pages {
+			if page.TargetID != parent.P.TargetID {
+				h.attach(page, string(page.SessionID), page.TargetID, false)
+			}
+		}
+	}
+
+	h.stop = func() {
+		// the popups opened after the recording stops aren't paused anymore
+		h.lock.Lock()
+		for _, session := range h.sessions {
+			_ = proto.TargetSetAutoAttach{AutoAttach: false, Flatten: true}.Call(session)
+		}
+		h.lock.Unlock()
+
+		recoverTargets()
+		stopEvents()
+		h.bodies.Wait()
+	}
+}
+
+// StopHAR stops recording the requests, the recorded ones can still be read
+func (parent *Runner) StopHAR() {
+	if parent.har != nil {
+		parent.har.stop()
+		parent.har.stop = func() {}
+	}
+}
+
+// HAR returns the requests recorded since RecordHAR, it is nil if the runner isn't recording
+func (parent *Runner) HAR() *HAR {
+	if parent.har == nil {
+		return nil
+	}
+	return parent.har.document()
+}
+
+// SaveHAR writes the requests recorded since RecordHAR to a HAR file
+func (parent *Runner) SaveHAR(path string) error {
+	har := parent.HAR()
+	if har == nil {
+		har = newHAR(nil)
+	}
+	bin, err := json.MarshalIndent(har, "", "  ")
+	if err != nil {
+		return err
+	}
+	return kit.OutputFile(path, bin, nil)
+}
+
+// attach records the requests of the session of a page, unless its target is already recorded. The popups that the
+// page opens are paused until they are attached too, so that their first requests are recorded, and a session that
+// is paused, as such a popup is, is resumed once its requests are recorded.
+func (h *harRecorder) attach(session proto.Caller, id string, target proto.TargetTargetID, waiting bool) {
+	h.lock.Lock()
+	recorded := h.targets[target]
+	if !recorded {
+		h.targets[target] = true
+		h.sessions[id] = session
+	}
+	h.lock.Unlock()
+
+	if !recorded {
+		err := proto.NetworkEnable{}.Call(session)
+		if err == nil {
+			err = proto.TargetSetAutoAttach{AutoAttach: true, WaitForDebuggerOnStart: true, Flatten: true}.Call(session)
+		}
+		if err != nil {
+			h.runner.Error("could not record the requests of a page: " + err.Error())
+		}
+	}
+	if waiting {
+		if err := (proto.RuntimeRunIfWaitingForDebugger{}).Call(session); err != nil {
+			h.runner.Error("could not resume a page: " + err.Error())
+		}
+	}
+}
+
+func (h *harRecorder) handle(e *cdp.Event) {
+	attached := &proto.TargetAttachedToTarget{}
+	if rod.Event(e, attached) {
+		session := harSession{browser: h.runner.B, id: string(attached.SessionID)}
+		if attached.TargetInfo.Type == proto.TargetTargetInfoTypePage {
+			go h.attach(session, session.id, attached.TargetInfo.TargetID, attached.WaitingForDebugger)
+		} else if attached.WaitingForDebugger {
+			// the other targets that are attached to, such as workers, are only resumed
+			go func() { _ = proto.RuntimeRunIfWaitingForDebugger{}.Call(session) }()
+		}
+		return
+	}
+
+	created := &proto.TargetTargetCreated{}
+	if rod.Event(e, created) {
+		h.lock.Lock()
+		// the popups of the recorded pages are attached to by the browser
+		popup := h.targets[created.TargetInfo.OpenerID]
+		h.lock.Unlock()
+
+		if created.TargetInfo.Type == proto.TargetTargetInfoTypePage && !popup {
+			go func() {
+				page, err := h.runner.B.PageFromTargetIDE(created.TargetInfo.TargetID)
+				if err != nil {
+					h.runner.Error("could not record the requests of a page: " + err.Error())
+					return
+				}
+				h.attach(page, string(page.SessionID), page.TargetID, false)
+			}()
+		}
+		return
+	}
+
+	sent := &proto.NetworkRequestWillBeSent{}
+	received := &proto.NetworkResponseReceived{}
+	finished := &proto.NetworkLoadingFinished{}
+	failed := &proto.NetworkLoadingFailed{}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	page, ok := h.sessions[e.SessionID]
+	if !ok {
+		return
+	}
+
+	switch {
+	case rod.Event(e, sent):
+		id := e.SessionID + string(sent.RequestID)
+		// a redirect reuses the request id, the previous record is completed by the redirect response
+		if prev, ok := h.byID[id]; ok && sent.RedirectResponse != nil {
+			prev.response = sent.RedirectResponse
+			prev.finished = sent.Timestamp
+		}
+		record := &harRecord{request: sent}
+		h.byID[id] = record
+		h.entries = append(h.entries, record)
+
+	case rod.Event(e, received):
+		if record, ok := h.byID[e.SessionID+string(received.RequestID)]; ok {
+			record.response = received.Response
+		}
+
+	case rod.Event(e, finished):
+		if record, ok := h.byID[e.SessionID+string(finished.RequestID)]; ok {
+			record.finished = finished.Timestamp
+			record.size = finished.EncodedDataLength
+			h.bodies.Add(1)
+			go h.body(page, finished.RequestID, record)
+		}
+
+	case rod.Event(e, failed):
+		if record, ok := h.byID[e.SessionID+string(failed.RequestID)]; ok {
+			record.finished = failed.Timestamp
+			record.failure = failed.ErrorText
+		}
+	}
+}
+
+func (h *harRecorder) body(page proto.Caller, id proto.NetworkRequestID, record *harRecord) {
+	defer h.bodies.Done()
+
+	// requests without a body, such as redirects, fail here and are recorded without one
+	res, err := proto.NetworkGetResponseBody{RequestID: id}.Call(page)
+	if err != nil {
+		return
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	record.body = res.Body
+	record.base64 = res.Base64Encoded
+}
+
+func (h *harRecorder) document() *HAR {
+	h.bodies.Wait()
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	entries := []HAREntry{}
+	for _, record := range h.entries {
+		entries = append(entries, record.entry())
+	}
+	return newHAR(entries)
+}
+
+func newHAR(entries []HAREntry) *HAR {
+	if entries == nil {
+		entries = []HAREntry{}
+	}
+	return &HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "wayang", Version: "1.0"},
+		Entries: entries,
+	}}
+}
+
+func (record *harRecord) entry() HAREntry {
+	req := record.request.Request
+	entry := HAREntry{
+		Request: HARRequest{
+			Method:      req.Method,
+			URL:         req.URL + req.URLFragment,
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []HARNameValue{},
+			Headers:     harHeaders(req.Headers),
+			QueryString: []HARNameValue{},
+			HeadersSize: -1,
+			BodySize:    len(req.PostData),
+		},
+		Response: HARResponse{
+			Cookies:     []HARNameValue{},
+			Headers:     []HARNameValue{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Timings: HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1},
+		Comment: record.failure,
+	}
+	if record.request.WallTime != nil {
+		entry.StartedDateTime = record.request.WallTime.Time
+	}
+	if u, err := url.Parse(req.URL); err == nil {
+		for name, values := range u.Query() {
+			for _, value := range values {
+				entry.Request.QueryString = append(entry.Request.QueryString, HARNameValue{name, value})
+			}
+		}
+	}
+	if req.HasPostData {
+		entry.Request.PostData = &HARPostData{MimeType: headerValue(req.Headers, "Content-Type"), Text: req.PostData}
+	}
+
+	if res := record.response; res != nil {
+		switch protocol := strings.ToLower(res.Protocol); {
+		case protocol == "h2":
+			entry.Request.HTTPVersion = "HTTP/2.0"
+		case strings.HasPrefix(protocol, "h3"):
+			entry.Request.HTTPVersion = "HTTP/3.0"
+		case protocol == "http/1.0":
+			entry.Request.HTTPVersion = "HTTP/1.0"
+		}
+
+		entry.Response.Status = res.Status
+		entry.Response.StatusText = res.StatusText
+		entry.Response.HTTPVersion = entry.Request.HTTPVersion
+		entry.Response.Headers = harHeaders(res.Headers)
+		entry.Response.RedirectURL = headerValue(res.Headers, "Location")
+		entry.Response.BodySize = int(record.size)
+		entry.ServerIPAddress = res.RemoteIPAddress
+
+		entry.Response.Content = HARContent{MimeType: res.MIMEType, Text: record.body}
+		if record.base64 {
+			entry.Response.Content.Encoding = "base64"
+			bin, _ := base64.StdEncoding.DecodeString(record.body)
+			entry.Response.Content.Size = len(bin)
+		} else {
+			entry.Response.Content.Size = len(record.body)
+		}
+	}
+
+	record.timings(&entry)
+	return entry
+}
+
+// timings fills the time of the entry from the resource timing of the response, when it has one
+func (record *harRecord) timings(entry *HAREntry) {
+	total := 0.0
+	if record.finished != nil && record.request.Timestamp != nil {
+		total = float64(record.finished.Duration-record.request.Timestamp.Duration) / float64(time.Millisecond)
+	}
+
+	var timing *proto.NetworkResourceTiming
+	if record.response != nil {
+		timing = record.response.Timing
+	}
+	if timing == nil {
+		entry.Timings.Send = 0
+		entry.Timings.Wait = total
+		entry.Timings.Receive = 0
+		entry.Time = total
+		return
+	}
+
+	span := func(start, end float64) float64 {
+		if start < 0 || end < 0 {
+			return -1
+		}
+		return end - start
+	}
+
+	entry.Timings.Blocked = -1
+	if record.request.Timestamp != nil {
+		entry.Timings.Blocked = (timing.RequestTime - record.request.Timestamp.Duration.Seconds()) * 1000
+	}
+	entry.Timings.DNS = span(timing.DNSStart, timing.DNSEnd)
+	entry.Timings.Connect = span(timing.ConnectStart, timing.ConnectEnd)
+	entry.Timings.SSL = span(timing.SslStart, timing.SslEnd)
+	entry.Timings.Send = span(timing.SendStart, timing.SendEnd)
+	entry.Timings.Wait = span(timing.SendEnd, timing.ReceiveHeadersEnd)
+	entry.Timings.Receive = 0
+	if record.finished != nil {
+		entry.Timings.Receive = (record.finished.Duration.Seconds()-timing.RequestTime)*1000 - timing.ReceiveHeadersEnd
+	}
+
+	entry.Time = 0
+	for _, t := range []float64{
+		entry.Timings.Blocked, entry.Timings.DNS, entry.Timings.Connect,
+		entry.Timings.Send, entry.Timings.Wait, entry.Timings.Receive,
+	} {
+		if t > 0 {
+			entry.Time += t
+		}
+	}
+}
+
+// headerValue returns the value of the header, the names of the headers of HTTP/2 are in lower case
+func headerValue(headers proto.NetworkHeaders, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value.String()
+		}
+	}
+	return ""
+}
+
+// harHeaders splits the headers that the browser joins with new lines, such as Set-Cookie
+func harHeaders(headers proto.NetworkHeaders) []HARNameValue {
+	list := []HARNameValue{}
+	for name, value := range headers {
+		for _, line := range strings.Split(value.String(), "\n") {
+			list = append(list, HARNameValue{name, line})
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// harRoutes turns the entries of the HAR file of the route into routes that fulfill the same requests,
+// restricted to the url, regex and method of the route. When the route aborts, the requests it matches
+// that the HAR file doesn't have are aborted.
+func harRoutes(route *Route) ([]*compiledRoute, error) {
+	filter, err := compileURL(route.URL, route.Regex)
+	if err != nil {
+		return nil, err
+	}
+
+	har := &HAR{}
+	bin, err := ioutil.ReadFile(route.HAR)
+	if err == nil {
+		err = json.Unmarshal(bin, har)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	routes := []*compiledRoute{}
+	if route.Abort {
+		routes = append(routes, &compiledRoute{Route: route, origin: route, url: filter})
+	}
+
+	for _, entry := range har.Log.Entries {
+		req, res := entry.Request, entry.Response
+		if filter != nil && !filter.MatchString(req.URL) ||
+			route.Method != "" && !strings.EqualFold(route.Method, req.Method) {
+			continue
+		}
+
+		replay := &Route{Method: req.Method, Status: int(res.Status), Headers: map[string]string{}, Delay: route.Delay}
+		if res.Status == 0 {
+			replay.Abort = true
+			replay.Reason = route.Reason
+		}
+		for _, header := range res.Headers {
+			// the recorded body is already decoded, and its length may differ
+			switch strings.ToLower(header.Name) {
+			case "content-encoding", "content-length", "transfer-encoding":
+				continue
+			}
+			// the repeated headers, such as Set-Cookie, are joined with new lines like the browser does,
+			// the router sends each line as a header
+			name := header.Name
+			for key := range replay.Headers {
+				if strings.EqualFold(key, name) {
+					name = key
+				}
+			}
+			if prev, ok := replay.Headers[name]; ok {
+				replay.Headers[name] = prev + "\n" + header.Value
+			} else {
+				replay.Headers[name] = header.Value
+			}
+		}
+
+		body := []byte(res.Content.Text)
+		if res.Content.Encoding == "base64" {
+			body, err = base64.StdEncoding.DecodeString(res.Content.Text)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		routes = append(routes, &compiledRoute{
+			Route:  replay,
+			origin: route,
+			url:    regexp.MustCompile("^" + regexp.QuoteMeta(req.URL) + "$"),
+			body:   body,
+		})
+	}
+	return routes, nil
+}
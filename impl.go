@@ -1,20 +1,28 @@
 package wayang
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"github.com/ysmood/kit"
+	"path/filepath"
+	"regexp"
 	"runtime/debug"
 	"strings"
 	"time"
 
 	"github.com/go-rod/rod"
 	"github.com/go-rod/rod/lib/input"
+	"github.com/go-rod/rod/lib/proto"
 )
 
 type runtimeAction struct {
 	runner *Runner
 	act    Action
 	source string
+
+	// the page that the action runs on, the one of the runner unless an assertion bounds it with its deadline
+	page *rod.Page
 }
 
 type actionFunc func(ra runtimeAction, act Action) interface{}
@@ -23,48 +31,96 @@ var actions map[string]actionFunc
 
 func init() {
 	actions = map[string]actionFunc{
-		"do":             doAction,
-		"forEach":        forEachAction,
-		"if":             ifAction,
-		"store":          storeAction,
-		"attribute":      attributeAction,
-		"html":           htmlAction,
-		"text":           textAction,
-		"has":            hasAction,
-		"not":            notAction,
-		"textContains":   textContainsAction,
-		"textEqual":      textEqualAction,
-		"textNotEqual":   textNotEqualAction,
-		"visible":        visibleAction,
-		"blur":           blurAction,
-		"clear":          clearAction,
-		"click":          clickAction,
-		"error":          errorAction,
-		"eval":           evalAction,
-		"focus":          focusAction,
-		"input":          inputAction,
-		"log":            logAction,
-		"logStore":       logStoreAction,
-		"navigate":       navigateAction,
-		"press":          pressAction,
-		"scrollIntoView": scrollIntoViewAction,
-		"selectAll":      selectAllAction,
-		"sleep":          sleepAction,
-		"waitIdle":       waitIdleAction,
-		"waitInvisible":  waitInvisibleAction,
-		"waitLoad":       waitLoadAction,
-		"waitStable":     waitStableAction,
-		"waitVisible":    waitVisibleAction,
+		"do":              doAction,
+		"forEach":         forEachAction,
+		"if":              ifAction,
+		"store":           storeAction,
+		"assert":          assertAction,
+		"expect":          assertAction,
+		"attribute":       attributeAction,
+		"html":            htmlAction,
+		"text":            textAction,
+		"and":             andAction,
+		"greaterThan":     greaterThanAction,
+		"has":             hasAction,
+		"lessThan":        lessThanAction,
+		"not":             notAction,
+		"numberEqual":     numberEqualAction,
+		"or":              orAction,
+		"textContains":    textContainsAction,
+		"textEqual":       textEqualAction,
+		"textMatches":     textMatchesAction,
+		"textNotEqual":    textNotEqualAction,
+		"visible":         visibleAction,
+		"attributes":      attributesAction,
+		"blockURLs":       blockURLsAction,
+		"blur":            blurAction,
+		"clear":           clearAction,
+		"clearCookies":    clearCookiesAction,
+		"clearStorage":    clearStorageAction,
+		"click":           clickAction,
+		"count":           countAction,
+		"deleteCookies":   deleteCookiesAction,
+		"disableCache":    disableCacheAction,
+		"emulate":         emulateAction,
+		"error":           errorAction,
+		"eval":            evalAction,
+		"extract":         extractAction,
+		"focus":           focusAction,
+		"getCookies":      getCookiesAction,
+		"getStorage":      getStorageAction,
+		"handleDialog":    handleDialogAction,
+		"input":           inputAction,
+		"loadState":       loadStateAction,
+		"log":             logAction,
+		"logStore":        logStoreAction,
+		"matchScreenshot": matchScreenshotAction,
+		"navigate":        navigateAction,
+		"noRequest":       noRequestAction,
+		"paginate":        paginateAction,
+		"pdf":             pdfAction,
+		"press":           pressAction,
+		"removeStorage":   removeStorageAction,
+		"resetNetwork":    resetNetworkAction,
+		"route":           routeAction,
+		"saveState":       saveStateAction,
+		"screenshot":      screenshotAction,
+		"scrollIntoView":  scrollIntoViewAction,
+		"selectAll":       selectAllAction,
+		"setCookies":      setCookiesAction,
+		"setOffline":      setOfflineAction,
+		"setStorage":      setStorageAction,
+		"table":           tableAction,
+		"texts":           textsAction,
+		"throttle":        throttleAction,
+		"unroute":         unrouteAction,
+		"sleep":           sleepAction,
+		"waitDialog":      waitDialogAction,
+		"waitDownload":    waitDownloadAction,
+		"waitIdle":        waitIdleAction,
+		"waitInvisible":   waitInvisibleAction,
+		"waitLoad":        waitLoadAction,
+		"waitRequest":     waitRequestAction,
+		"waitResponse":    waitResponseAction,
+		"waitStable":      waitStableAction,
+		"waitVisible":     waitVisibleAction,
 	}
 }
 
 func (parent *Runner) runAction(act Action, source string) interface{} {
+	return runtimeAction{runner: parent, page: parent.P}.runAction(act, source)
+}
+
+// runAction runs an action that the action is made of, on the same page
+func (ra runtimeAction) runAction(act Action, source string) interface{} {
+	parent := ra.runner
 	source = source + "." + act["action"].(string)
 
-	ra := runtimeAction{
+	ra = runtimeAction{
 		runner: parent,
 		act:    act,
 		source: source,
+		page:   ra.page,
 	}
 
 	if len(source) > 1000 {
@@ -89,11 +145,10 @@ func (parent *Runner) runAction(act Action, source string) interface{} {
 	if !ok {
 		return ra.err("could not find a custom action with the requested name (" + source + ")")
 	}
-	return parent.runAction(cActionFunc, source)
+	return ra.runAction(cActionFunc, source)
 }
 
 func doAction(ra runtimeAction, act Action) interface{} {
-	run := ra.runner
 	stmts, ok := act["statements"].([]interface{})
 	var res interface{}
 	if !ok {
@@ -103,7 +158,7 @@ func doAction(ra runtimeAction, act Action) interface{} {
 		}
 
 		for _, action := range typed {
-			res = run.runAction(action, ra.source)
+			res = ra.runAction(action, ra.source)
 			if _, ok = res.(RuntimeError); ok {
 				return res
 			}
@@ -111,7 +166,7 @@ func doAction(ra runtimeAction, act Action) interface{} {
 	} else {
 		for _, rawAction := range stmts {
 			stmt := Action(rawAction.(map[string]interface{}))
-			res = run.runAction(stmt, ra.source)
+			res = ra.runAction(stmt, ra.source)
 			if _, ok = res.(RuntimeError); ok {
 				return res
 			}
@@ -139,9 +194,9 @@ func forEachAction(ra runtimeAction, act Action) interface{} {
 		return ra.err("could not find a custom selector defined with the specified value")
 	}
 
-	for _, element := range run.P.ElementsX(sel) {
+	for _, element := range ra.page.ElementsX(sel) {
 		action["element"] = element // needs to be the xpath
-		if res, ok := run.runAction(action, ra.source).(RuntimeError); ok {
+		if res, ok := ra.runAction(action, ra.source).(RuntimeError); ok {
 			return res
 		}
 	}
@@ -156,7 +211,7 @@ func ifAction(ra runtimeAction, act Action) interface{} {
 		return ra.err("a condition is required to be present")
 	}
 
-	res := run.runAction(*condition, ra.source)
+	res := ra.runAction(*condition, ra.source)
 	toBool, ok := res.(bool)
 	if !ok {
 		if _, ok := res.(RuntimeError); ok {
@@ -170,13 +225,13 @@ func ifAction(ra runtimeAction, act Action) interface{} {
 		if stmt == nil {
 			return ra.err("could not transform execute to action")
 		}
-		return run.runAction(*stmt, ra.source)
+		return ra.runAction(*stmt, ra.source)
 	}
 	action := run.makeAction(act["otherwise"])
 	if action == nil {
 		return nil
 	}
-	return run.runAction(*action, ra.source)
+	return ra.runAction(*action, ra.source)
 }
 
 func storeAction(ra runtimeAction, act Action) interface{} {
@@ -192,7 +247,7 @@ func storeAction(ra runtimeAction, act Action) interface{} {
 		switch item := field.(type) {
 		case map[string]interface{}:
 			source = fmt.Sprintf("%s.field[%s]", source, s)
-			res := run.runAction(item, source)
+			res := ra.runAction(item, source)
 			if err, ok := res.(RuntimeError); ok {
 				return err
 			}
@@ -200,7 +255,7 @@ func storeAction(ra runtimeAction, act Action) interface{} {
 
 		case Action:
 			source = fmt.Sprintf("%s.field[%s]", source, s)
-			res := run.runAction(item, source)
+			res := ra.runAction(item, source)
 			if err, ok := res.(RuntimeError); ok {
 				return err
 			}
@@ -219,7 +274,7 @@ func storeAction(ra runtimeAction, act Action) interface{} {
 			}
 
 			if action, ok := run.program.Actions[value]; ok {
-				res := run.runAction(action, source)
+				res := ra.runAction(action, source)
 				if err, ok := res.(RuntimeError); ok {
 					return err
 				}
@@ -269,6 +324,14 @@ func textAction(ra runtimeAction, act Action) interface{} {
 	return element.Text()
 }
 
+func andAction(ra runtimeAction, act Action) interface{} {
+	return ra.combine(act, false)
+}
+
+func greaterThanAction(ra runtimeAction, act Action) interface{} {
+	return ra.compareNumber(act, func(actual, value float64) bool { return actual > value })
+}
+
 func hasAction(ra runtimeAction, act Action) interface{} {
 	run := ra.runner
 
@@ -281,7 +344,7 @@ func hasAction(ra runtimeAction, act Action) interface{} {
 	if !ok {
 		return ra.err("could not find a custom selector defined with the specified value")
 	}
-	return run.P.HasX(sel)
+	return ra.page.HasX(sel)
 }
 
 func notAction(ra runtimeAction, act Action) interface{} {
@@ -296,7 +359,7 @@ func notAction(ra runtimeAction, act Action) interface{} {
 		return ra.err("a statement is required to be present")
 	}
 
-	res := run.runAction(*stmtAttr, ra.source)
+	res := ra.runAction(*stmtAttr, ra.source)
 	toBool, ok := res.(bool)
 	if !ok {
 		if _, ok := res.(RuntimeError); ok {
@@ -308,6 +371,82 @@ func notAction(ra runtimeAction, act Action) interface{} {
 	return !toBool
 }
 
+func lessThanAction(ra runtimeAction, act Action) interface{} {
+	return ra.compareNumber(act, func(actual, value float64) bool { return actual < value })
+}
+
+func numberEqualAction(ra runtimeAction, act Action) interface{} {
+	return ra.compareNumber(act, func(actual, value float64) bool { return actual == value })
+}
+
+func orAction(ra runtimeAction, act Action) interface{} {
+	return ra.combine(act, true)
+}
+
+// combine the results of the statements, which are bool values or actions returning them, with "or" when any is true,
+// or with "and" otherwise. The statements after the one that decides the result don't run.
+func (ra runtimeAction) combine(act Action, any bool) interface{} {
+	run := ra.runner
+
+	stmts, ok := act["statements"].([]interface{})
+	if !ok || len(stmts) == 0 {
+		return ra.err("a 'statements' key (type []action) is required to be present")
+	}
+
+	for i, stmt := range stmts {
+		res := stmt
+		if action := run.makeAction(stmt); action != nil {
+			res = ra.runAction(*action, fmt.Sprintf("%s[%d]", ra.source, i))
+		}
+
+		toBool, ok := res.(bool)
+		if !ok {
+			if _, ok := res.(RuntimeError); ok {
+				return res
+			}
+			return ra.err("expected statement to return a bool type, got", res)
+		}
+		if toBool == any {
+			return any
+		}
+	}
+	return !any
+}
+
+// compareNumber compares the number of the statement, which is a number or the text of one such as "$1,234.50",
+// with the 'value' key
+func (ra runtimeAction) compareNumber(act Action, compare func(actual, value float64) bool) interface{} {
+	run := ra.runner
+
+	stmt := run.makeAction(act["statement"])
+	if stmt == nil {
+		return ra.err("a statement key (type action) is required to be present")
+	}
+	if _, ok := act["value"]; !ok {
+		return ra.err("a 'value' key (type number) is required to be present")
+	}
+
+	stmtRes := ra.runAction(*stmt, ra.source)
+	if _, ok := stmtRes.(RuntimeError); ok {
+		return stmtRes
+	}
+	actual, ok := toNumber(stmtRes)
+	if !ok {
+		return ra.err("expected statement to return a number, got", stmtRes)
+	}
+
+	valueRes, err := ra.value(act["value"])
+	if err != "" {
+		return ra.err("the value failed:", err)
+	}
+	value, ok := toNumber(valueRes)
+	if !ok {
+		return ra.err("expected the value to be a number, got", valueRes)
+	}
+
+	return compare(actual, value)
+}
+
 func textContainsAction(ra runtimeAction, act Action) interface{} {
 	run := ra.runner
 
@@ -321,7 +460,7 @@ func textContainsAction(ra runtimeAction, act Action) interface{} {
 		return ra.err("a statement key (type action) is required to be present")
 	}
 
-	stmtRes := run.runAction(*stmt, ra.source)
+	stmtRes := ra.runAction(*stmt, ra.source)
 	switch stmtRes.(type) {
 	case RuntimeError:
 		return stmtRes
@@ -357,7 +496,7 @@ func textEqualAction(ra runtimeAction, act Action) interface{} {
 		return ra.err("an actual key (type action) is required to be present")
 	}
 
-	actualRes := run.runAction(*stmt, ra.source)
+	actualRes := ra.runAction(*stmt, ra.source)
 	switch actualRes.(type) {
 	case RuntimeError:
 		return actualRes
@@ -380,6 +519,60 @@ func textEqualAction(ra runtimeAction, act Action) interface{} {
 	return expected == actual
 }
 
+func textMatchesAction(ra runtimeAction, act Action) interface{} {
+	run := ra.runner
+
+	pattern, ok := act["pattern"].(string)
+	if !ok {
+		return ra.err("a 'pattern' key (type string) is required to be present")
+	}
+	if ignoreCase, _ := act["ignoreCase"].(bool); ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return ra.err("could not compile the pattern:", err)
+	}
+
+	stmt := run.makeAction(act["statement"])
+	if stmt == nil {
+		return ra.err("a statement key (type action) is required to be present")
+	}
+
+	stmtRes := ra.runAction(*stmt, ra.source)
+	switch stmtRes.(type) {
+	case RuntimeError:
+		return stmtRes
+	case string:
+		break
+	default:
+		return ra.err("unexpected result from action: ", stmtRes)
+	}
+
+	groups := regex.FindStringSubmatch(stmtRes.(string))
+	if groups == nil {
+		return false
+	}
+
+	// the named groups are stored, and so are all the groups when the action has a 'store' key
+	if run.ENV == nil {
+		run.ENV = map[string]interface{}{}
+	}
+	for i, name := range regex.SubexpNames() {
+		if name != "" {
+			run.ENV[name] = groups[i]
+		}
+	}
+	if key, ok := act["store"].(string); ok {
+		list := []interface{}{}
+		for _, group := range groups {
+			list = append(list, group)
+		}
+		run.ENV[key] = list
+	}
+	return true
+}
+
 func textNotEqualAction(ra runtimeAction, act Action) interface{} {
 	res := textEqualAction(ra, act)
 	if boolRes, ok := res.(bool); ok {
@@ -441,7 +634,7 @@ func evalAction(ra runtimeAction, act Action) interface{} {
 	}
 
 	if _, ok := act["element"]; !ok {
-		return ra.runner.P.Eval(expression).Raw
+		return ra.page.Eval(expression).Raw
 	}
 
 	element, err := ra.createElem(act)
@@ -468,7 +661,7 @@ func inputAction(ra runtimeAction, act Action) interface{} {
 
 	element, err := ra.createElem(act)
 	if err != nil {
-		ra.runner.P.Keyboard.InsertText(text)
+		ra.page.Keyboard.InsertText(text)
 		return nil
 	}
 	element.Input(text)
@@ -508,10 +701,100 @@ func navigateAction(ra runtimeAction, act Action) interface{} {
 	if !ok {
 		return ra.err("a 'link' key (type string) is required to present")
 	}
-	ra.runner.P.Navigate(link)
+	ra.page.Navigate(link)
 	return nil
 }
 
+// paper sizes in inches, width by height
+var paperSizes = map[string][2]float64{
+	"letter":  {8.5, 11},
+	"legal":   {8.5, 14},
+	"tabloid": {11, 17},
+	"ledger":  {17, 11},
+	"a0":      {33.1, 46.8},
+	"a1":      {23.4, 33.1},
+	"a2":      {16.54, 23.4},
+	"a3":      {11.7, 16.54},
+	"a4":      {8.27, 11.7},
+	"a5":      {5.83, 8.27},
+	"a6":      {4.13, 5.83},
+}
+
+func pdfAction(ra runtimeAction, act Action) interface{} {
+	run := ra.runner
+
+	req := &proto.PagePrintToPDF{}
+	req.Landscape, _ = act["landscape"].(bool)
+	req.PrintBackground, _ = act["background"].(bool)
+	req.Scale, _ = act["scale"].(float64)
+	req.PageRanges, _ = act["pageRanges"].(string)
+	req.HeaderTemplate, _ = act["headerTemplate"].(string)
+	req.FooterTemplate, _ = act["footerTemplate"].(string)
+	req.DisplayHeaderFooter = req.HeaderTemplate != "" || req.FooterTemplate != ""
+
+	if paper, ok := act["paper"].(string); ok {
+		size, ok := paperSizes[strings.ToLower(paper)]
+		if !ok {
+			return ra.err("unknown paper size", paper)
+		}
+		req.PaperWidth, req.PaperHeight = size[0], size[1]
+	}
+	if width, ok := act["width"].(float64); ok {
+		req.PaperWidth = width
+	}
+	if height, ok := act["height"].(float64); ok {
+		req.PaperHeight = height
+	}
+
+	// the margins of proto.PagePrintToPDF are omitted when they are 0, which Chrome replaces with its default margin,
+	// so they are sent in the params as they are
+	margins := map[string]float64{}
+	switch margin := act["margin"].(type) {
+	case nil:
+	case float64:
+		for _, side := range []string{"Top", "Bottom", "Left", "Right"} {
+			margins["margin"+side] = margin
+		}
+	case map[string]interface{}:
+		for _, side := range []string{"top", "bottom", "left", "right"} {
+			if value, ok := margin[side].(float64); ok {
+				margins["margin"+strings.Title(side)] = value
+			}
+		}
+	default:
+		return ra.err("expected the 'margin' key to be a number or a map of sides, got", margin)
+	}
+
+	params := map[string]interface{}{}
+	bin, err := json.Marshal(req)
+	if err == nil {
+		err = json.Unmarshal(bin, &params)
+	}
+	if err != nil {
+		return ra.err("could not print the page to PDF:", err)
+	}
+	for name, value := range margins {
+		params[name] = value
+	}
+
+	res := proto.PagePrintToPDFResult{}
+	if err := proto.Call("Page.printToPDF", params, &res, ra.page); err != nil {
+		return ra.err("could not print the page to PDF:", err)
+	}
+	bin = res.Data
+
+	path, ok := act["path"].(string)
+	if !ok {
+		return bin
+	}
+
+	path = run.artifact(path)
+	if err := kit.OutputFile(path, bin, nil); err != nil {
+		return ra.err("could not write the PDF to a file:", err)
+	}
+	return path
+}
+
 func pressAction(ra runtimeAction, act Action) interface{} {
 	keyAttr, ok := act["key"].(string)
 	var press rune
@@ -532,13 +815,51 @@ func pressAction(ra runtimeAction, act Action) interface{} {
 
 	element, err := ra.createElem(act)
 	if err != nil {
-		ra.runner.P.Keyboard.Press(press)
+		ra.page.Keyboard.Press(press)
 		return nil
 	}
 	element.Press(press)
 	return nil
 }
 
+func screenshotAction(ra runtimeAction, act Action) interface{} {
+	run := ra.runner
+
+	format := proto.PageCaptureScreenshotFormatPng
+	switch act["format"] {
+	case nil, "png":
+	case "jpeg", "jpg":
+		format = proto.PageCaptureScreenshotFormatJpeg
+	default:
+		return ra.err("the 'format' key must be either 'png' or 'jpeg', got", act["format"])
+	}
+
+	quality := -1
+	if q, ok := act["quality"].(float64); ok {
+		if format != proto.PageCaptureScreenshotFormatJpeg {
+			return ra.err("the 'quality' key is only supported by the 'jpeg' format")
+		}
+		quality = int(q)
+	}
+
+	bin, rErr := ra.screenshot(act, format, quality)
+	if rErr != nil {
+		return *rErr
+	}
+
+	path, ok := act["path"].(string)
+	if !ok {
+		return base64.StdEncoding.EncodeToString(bin)
+	}
+
+	path = run.artifact(path)
+	if err := kit.OutputFile(path, bin, nil); err != nil {
+		return ra.err("could not write the screenshot to a file:", err)
+	}
+	run.attach(path)
+	return path
+}
+
 func scrollIntoViewAction(ra runtimeAction, act Action) interface{} {
 	element, err := ra.createElem(act)
 	if err != nil {
@@ -566,7 +887,7 @@ func sleepAction(ra runtimeAction, act Action) interface{} {
 		return ra.err("duration value must be greater than or equal to 0")
 	}
 
-	ctx := ra.runner.P.GetContext()
+	ctx := ra.page.GetContext()
 	asFloat := float64(time.Second) * duration
 	t := time.After(time.Duration(asFloat))
 
@@ -580,7 +901,7 @@ func sleepAction(ra runtimeAction, act Action) interface{} {
 
 func waitIdleAction(ra runtimeAction, _ Action) interface{} {
 	return ra.waitWithTimeout(func() {
-		ra.runner.P.WaitRequestIdle()()
+		ra.page.WaitRequestIdle()()
 	}, "waited too long for waitInvisible action to complete")
 }
 
@@ -597,7 +918,7 @@ func waitInvisibleAction(ra runtimeAction, act Action) interface{} {
 
 func waitLoadAction(ra runtimeAction, _ Action) interface{} {
 	return ra.waitWithTimeout(func() {
-		ra.runner.P.WaitLoad()
+		ra.page.WaitLoad()
 	}, "waited too long for waitLoad action to complete")
 }
 
@@ -625,9 +946,7 @@ func waitVisibleAction(ra runtimeAction, act Action) interface{} {
 }
 
 func (ra runtimeAction) waitWithTimeout(wait func(), timeoutMsg string) interface{} {
-	run := ra.runner
-
-	ctx := run.P.GetContext()
+	ctx := ra.page.GetContext()
 
 	done := make(chan bool, 1)
 	go func() {
@@ -681,7 +1000,7 @@ func (ra runtimeAction) createElem(act Action) (*rod.Element, *RuntimeError) {
 			return nil, &err
 		}
 
-		element = run.P.ElementX(sel)
+		element = ra.page.ElementX(sel)
 	case *rod.Element:
 		element = typed
 	default:
@@ -691,6 +1010,30 @@ func (ra runtimeAction) createElem(act Action) (*rod.Element, *RuntimeError) {
 	return element, nil
 }
 
+func (ra runtimeAction) screenshot(act Action, format proto.PageCaptureScreenshotFormat, quality int) ([]byte, *RuntimeError) {
+	var bin []byte
+	var err error
+	if _, ok := act["element"]; ok {
+		element, rErr := ra.createElem(act)
+		if rErr != nil {
+			return nil, rErr
+		}
+		bin, err = element.ScreenshotE(format, quality)
+	} else {
+		fullPage, _ := act["fullPage"].(bool)
+		req := &proto.PageCaptureScreenshot{Format: format}
+		if quality > -1 {
+			req.Quality = int64(quality)
+		}
+		bin, err = ra.page.ScreenshotE(fullPage, req)
+	}
+	if err != nil {
+		rErr := ra.err("could not capture the screenshot:", err)
+		return nil, &rErr
+	}
+	return bin, nil
+}
+
 func (parent *Runner) makeAction(act interface{}) *Action {
 	switch act.(type) {
 	case map[string]interface{}:
@@ -704,6 +1047,18 @@ func (parent *Runner) makeAction(act interface{}) *Action {
 	}
 }
 
+func (parent *Runner) artifact(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(parent.ArtifactsDir, path)
+}
+
+// attach the screenshot to the result of the running step
+func (parent *Runner) attach(path string) {
+	parent.screenshots = append(parent.screenshots, path)
+}
+
 func (parent *Runner) sel(element string) (string, bool) {
 	if strings.HasPrefix(element, "$") {
 		res, ok := parent.program.Selectors[strings.TrimPrefix(element, "$")]
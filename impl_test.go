@@ -1,8 +1,17 @@
 package wayang_test
 
 import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
 
+	"github.com/go-rod/rod/lib/proto"
 	"github.com/ysmood/kit"
 
 	"github.com/go-rod/wayang"
@@ -232,6 +241,30 @@ func (s *S) TestError() {
 	s.Equal([]interface{}{"text"}, err.ErrorRaw())
 }
 
+func (s *S) TestErrorSnapshot() {
+	s.page.Navigate(srcFile("fixtures/click.html"))
+
+	_, err := s.singleAction(action(
+		"action", "error",
+		"message", "text",
+	))
+	snap := err.Snapshot()
+	s.Equal(srcFile("fixtures/click.html"), snap.URL)
+	s.Contains(snap.HTML, "<button")
+	s.Equal("\x89PNG", string(snap.Screenshot[:4]))
+
+	dir, e := ioutil.TempDir("", "wayang")
+	kit.E(e)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	paths, e := snap.Write(dir)
+	s.Nil(e)
+	s.Len(paths, 3)
+	for _, path := range paths {
+		s.FileExists(path)
+	}
+}
+
 func (s *S) TestEval() {
 	res, _ := s.singleAction(action(
 		"action", "eval",
@@ -289,14 +322,1792 @@ func (s *S) TestNavigate() {
 	s.Equal(url, s.pageURL())
 }
 
-func (s *S) TestScrollIntoView() {
+func (s *S) TestPDF() {
 	s.page.Navigate(srcFile("fixtures/input.html"))
 
 	res, _ := s.singleAction(action(
-		"action", "scrollIntoView",
-		"element", "//form",
+		"action", "pdf",
+		"paper", "a4",
+		"landscape", true,
+		"margin", map[string]interface{}{"top": 1.0},
+		"footerTemplate", `<span class="pageNumber"></span>`,
 	))
-	s.Nil(res)
+	s.Equal("%PDF", string(res.([]byte)[:4]))
+
+	// the content is as tall as a letter page, so it only fits on one page without a margin
+	s.page.Eval(`() => {
+		document.body.style.margin = '0'
+		document.body.innerHTML = '<div style="height: 10.9in"></div>'
+	}`)
+	pages := regexp.MustCompile(`/Type\s*/Page\b`)
+	pdf := func(margin interface{}) int {
+		act := action("action", "pdf", "paper", "letter")
+		if margin != nil {
+			act["margin"] = margin
+		}
+		res, _ := s.singleAction(act)
+		return len(pages.FindAll(res.([]byte), -1))
+	}
+	s.Equal(2, pdf(nil))
+	s.Equal(1, pdf(0.0))
+	s.Equal(1, pdf(map[string]interface{}{"top": 0.0, "bottom": 0.0}))
+}
+
+func (s *S) TestRoute() {
+	run := s.runner()
+	defer run.Unroute(&wayang.Route{})
+
+	res, err := run.RunProgram(program(`{
+		"mocks": [
+			{
+				"url": "http://wayang.test/",
+				"headers": { "Content-Type": "text/html" },
+				"body": "<html><body><h1>mocked</h1></body></html>"
+			}
+		],
+		"steps": [
+			{
+				"action": "route",
+				"regex": "/api$",
+				"method": "GET",
+				"status": 201,
+				"body": { "ok": true }
+			},
+			{
+				"action": "route",
+				"url": "http://wayang.test/blocked",
+				"abort": true
+			},
+			{
+				"action": "route",
+				"url": "*/items(1)",
+				"body": "items"
+			},
+			{
+				"action": "route",
+				"url": "*/search?q=a+b",
+				"body": "search"
+			},
+			{
+				"action": "route",
+				"url": "*/a[1].txt",
+				"body": "file"
+			},
+			{
+				"action": "navigate",
+				"link": "http://wayang.test/"
+			},
+			{
+				"action": "store",
+				"items": {
+					"globs": {
+						"action": "eval",
+						"expression": "() => Promise.all(['/items(1)', '/search?q=a+b', '/a[1].txt', '/a[1]xtxt'].map(u => fetch(u).then(r => r.text(), () => 'failed'))).then(l => l.join(','))"
+					},
+					"api": {
+						"action": "eval",
+						"expression": "() => fetch('/api').then(r => r.status + ' ' + r.headers.get('content-type'))"
+					},
+					"blocked": {
+						"action": "eval",
+						"expression": "() => fetch('/blocked').then(() => 'ok', () => 'failed')"
+					}
+				}
+			},
+			{
+				"action": "text",
+				"element": "//h1"
+			}
+		]
+	}`))
+	s.Nil(err)
+	s.Equal("mocked", res)
+	s.Equal(`"201 application/json; charset=utf-8"`, run.ENV["api"])
+	s.Equal(`"failed"`, run.ENV["blocked"])
+	s.Equal(`"items,search,file,failed"`, run.ENV["globs"])
+}
+
+func (s *S) TestRouteHeaders() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Join(r.Header["X-Test"], ",")))
+	}))
+	defer server.Close()
+
+	run := s.runner()
+	defer run.Unroute(&wayang.Route{})
+
+	prog := program(`{
+		"mocks": [
+			{ "url": "*/echo", "setHeaders": { "x-test": "set" } },
+			{ "url": "*/api", "body": { "ok": true } }
+		],
+		"steps": [
+			{
+				"action": "navigate",
+				"link": "` + server.URL + `"
+			},
+			{
+				"action": "eval",
+				"expression": "() => fetch('/echo', { headers: { 'X-Test': 'sent' } }).then(r => r.text())"
+			}
+		]
+	}`)
+	for i := 0; i < 2; i++ {
+		res, rErr := run.RunProgram(prog)
+		s.Nil(rErr)
+		s.Equal(`"set"`, res)
+	}
+	// the content type of the json body isn't added to the mock of the program
+	s.Nil(prog.Mocks[1].Headers)
+}
+
+func (s *S) TestNetworkAssertions() {
+	run := s.runner()
+	defer run.Unroute(&wayang.Route{})
+
+	_, err := run.RunProgram(program(`{
+		"mocks": [
+			{
+				"url": "http://wayang.test/",
+				"headers": { "Content-Type": "text/html" },
+				"body": "<html><body></body></html>"
+			},
+			{
+				"url": "*/api",
+				"status": 503,
+				"body": { "ok": false }
+			}
+		],
+		"steps": [
+			{
+				"action": "navigate",
+				"link": "http://wayang.test/"
+			},
+			{
+				"action": "store",
+				"items": {
+					"response": {
+						"action": "waitResponse",
+						"url": "*/api",
+						"method": "POST",
+						"duration": 3,
+						"statement": {
+							"action": "eval",
+							"expression": "() => { fetch('/api', { method: 'POST', body: '{\\"id\\": 1}' }) }"
+						}
+					}
+				}
+			},
+			{
+				"action": "store",
+				"items": {
+					"request": {
+						"action": "waitRequest",
+						"url": "*/api",
+						"duration": 3
+					}
+				}
+			},
+			{
+				"action": "noRequest",
+				"status": "4xx"
+			}
+		]
+	}`))
+	s.Nil(err)
+
+	response := run.ENV["response"].(map[string]interface{})
+	s.EqualValues(503, response["status"])
+	s.Equal(map[string]interface{}{"ok": false}, response["body"])
+
+	request := run.ENV["request"].(map[string]interface{})
+	s.Equal("POST", request["method"])
+	s.Equal(map[string]interface{}{"id": 1.0}, request["body"])
+
+	// the requests of the previous programs are not in the log of the next one
+	_, err = run.RunAction(action(
+		"action", "noRequest",
+		"status", "5xx",
+	))
+	s.Nil(err)
+
+	_, err = run.RunActions([]wayang.Action{
+		{"action": "eval", "expression": "() => { fetch('/api') }"},
+		{"action": "waitResponse", "url": "*/api", "duration": 3.0},
+		{"action": "noRequest", "status": "5xx"},
+	})
+	s.NotNil(err)
+}
+
+func (s *S) TestHAR() {
+	dir, err := ioutil.TempDir("", "wayang")
+	kit.E(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "run.har")
+
+	run := s.runner()
+	run.RecordHAR()
+	_, rErr := run.RunProgram(program(`{
+		"mocks": [
+			{
+				"url": "http://wayang.test/",
+				"headers": { "Content-Type": "text/html" },
+				"body": "<html><body><h1>recorded</h1></body></html>"
+			}
+		],
+		"steps": [
+			{
+				"action": "navigate",
+				"link": "http://wayang.test/"
+			}
+		]
+	}`))
+	s.Nil(rErr)
+	run.Unroute(&wayang.Route{})
+	run.StopHAR()
+	s.Nil(run.SaveHAR(path))
+
+	har := &wayang.HAR{}
+	kit.E(kit.ReadJSON(path, har))
+	s.Equal("1.2", har.Log.Version)
+	entry := har.Log.Entries[len(har.Log.Entries)-1]
+	s.Equal("http://wayang.test/", entry.Request.URL)
+	s.EqualValues(200, entry.Response.Status)
+	s.Contains(entry.Response.Content.Text, "recorded")
+
+	// the repeated headers are replayed separately, the names of the headers of HTTP/2 are in lower case
+	har.Log.Entries[len(har.Log.Entries)-1].Response.Headers = append(entry.Response.Headers,
+		wayang.HARNameValue{Name: "set-cookie", Value: "a=1"},
+		wayang.HARNameValue{Name: "Set-Cookie", Value: "b=2"},
+	)
+	kit.E(kit.OutputFile(path, har, nil))
+
+	run = s.runner()
+	defer run.Unroute(&wayang.Route{})
+	res, rErr := run.RunProgram(wayang.Program{
+		Mocks: []wayang.Route{{HAR: path, Abort: true}},
+		Steps: []wayang.Action{
+			action("action", "navigate", "link", "http://wayang.test/"),
+			action("action", "store", "items", map[string]interface{}{
+				"other": action(
+					"action", "eval",
+					"expression", "() => fetch('/other').then(() => 'ok', () => 'failed')",
+				),
+			}),
+			action("action", "text", "element", "//h1"),
+		},
+	})
+	s.Nil(rErr)
+	s.Equal("recorded", res)
+	s.Equal(`"failed"`, run.ENV["other"])
+
+	cookie, _ := run.RunAction(action("action", "eval", "expression", "() => document.cookie"))
+	s.Equal(`"a=1; b=2"`, cookie)
+	run.RunAction(action("action", "clearCookies"))
+}
+
+func (s *S) TestHARPopup() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/popup" {
+			_, _ = w.Write([]byte("<html><body>popup</body></html>"))
+			return
+		}
+		_, _ = w.Write([]byte("<html><body><a href='/popup' target='_blank'>open</a></body></html>"))
+	}))
+	defer server.Close()
+
+	run := s.runner()
+	run.RecordHAR()
+	defer run.StopHAR()
+
+	_, rErr := run.RunProgram(program(`{
+		"steps": [
+			{ "action": "navigate", "link": "` + server.URL + `" },
+			{ "action": "click", "element": "//a" }
+		]
+	}`))
+	s.Nil(rErr)
+
+	// the document request of the popup is recorded, as it is sent before the popup could be attached to otherwise
+	var popup *wayang.HAREntry
+	for start := time.Now(); popup == nil && time.Since(start) < 5*time.Second; time.Sleep(100 * time.Millisecond) {
+		for _, entry := range run.HAR().Log.Entries {
+			if entry.Request.URL == server.URL+"/popup" && entry.Response.Status == 200 {
+				entry := entry
+				popup = &entry
+			}
+		}
+	}
+	s.NotNil(popup)
+
+	targets, err := proto.TargetGetTargets{}.Call(s.browser)
+	kit.E(err)
+	for _, target := range targets.TargetInfos {
+		if target.URL == server.URL+"/popup" {
+			_, _ = proto.TargetCloseTarget{TargetID: target.TargetID}.Call(s.browser)
+		}
+	}
+}
+
+func (s *S) TestCookies() {
+	run := s.runner()
+	defer run.RunAction(action("action", "clearCookies"))
+
+	_, err := run.RunProgram(program(`{
+		"steps": [
+			{
+				"action": "setCookies",
+				"cookies": [
+					{ "name": "session", "value": "a", "url": "http://wayang.test/" },
+					{ "name": "theme", "value": "dark", "domain": "wayang.test", "path": "/" },
+					{ "name": "session", "value": "b", "domain": "other.test", "path": "/" }
+				]
+			},
+			{
+				"action": "store",
+				"items": {
+					"sessions": {
+						"action": "getCookies",
+						"name": "session"
+					}
+				}
+			},
+			{
+				"action": "store",
+				"items": {
+					"deleted": {
+						"action": "deleteCookies",
+						"domain": "wayang.test"
+					}
+				}
+			},
+			{
+				"action": "store",
+				"items": {
+					"left": {
+						"action": "getCookies"
+					}
+				}
+			}
+		]
+	}`))
+	s.Nil(err)
+
+	s.Len(run.ENV["sessions"], 2)
+	s.Equal(2.0, run.ENV["deleted"])
+
+	left := run.ENV["left"].([]interface{})
+	s.Len(left, 1)
+	s.Equal("other.test", left[0].(map[string]interface{})["domain"])
+	s.Equal("b", left[0].(map[string]interface{})["value"])
+}
+
+func (s *S) TestStorage() {
+	run := s.runner()
+	defer run.Unroute(&wayang.Route{})
+
+	_, err := run.RunProgram(program(`{
+		"mocks": [
+			{
+				"url": "http://wayang.test/",
+				"headers": { "Content-Type": "text/html" },
+				"body": "<html><body></body></html>"
+			}
+		],
+		"steps": [
+			{
+				"action": "navigate",
+				"link": "http://wayang.test/"
+			},
+			{
+				"action": "clearStorage"
+			},
+			{
+				"action": "setStorage",
+				"items": {
+					"flags": { "beta": true },
+					"locale": "en-GB",
+					"tmp": "x"
+				}
+			},
+			{
+				"action": "setStorage",
+				"storage": "session",
+				"key": "step",
+				"value": 2
+			},
+			{
+				"action": "removeStorage",
+				"key": ["tmp"]
+			},
+			{
+				"action": "store",
+				"items": {
+					"flags": {
+						"action": "getStorage",
+						"key": "flags"
+					},
+					"missing": {
+						"action": "getStorage",
+						"key": "tmp"
+					}
+				}
+			},
+			{
+				"action": "store",
+				"items": {
+					"local": {
+						"action": "getStorage"
+					}
+				}
+			},
+			{
+				"action": "store",
+				"items": {
+					"session": {
+						"action": "getStorage",
+						"storage": "session"
+					}
+				}
+			}
+		]
+	}`))
+	s.Nil(err)
+
+	s.Equal(map[string]interface{}{"beta": true}, run.ENV["flags"])
+	s.Nil(run.ENV["missing"])
+	s.Equal(map[string]interface{}{"flags": map[string]interface{}{"beta": true}, "locale": "en-GB"}, run.ENV["local"])
+	s.Equal(map[string]interface{}{"step": 2.0}, run.ENV["session"])
+}
+
+func (s *S) TestState() {
+	dir, err := ioutil.TempDir("", "wayang")
+	kit.E(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	run := s.runner()
+	defer run.Unroute(&wayang.Route{})
+	defer run.RunAction(action("action", "clearCookies"))
+
+	_, rErr := run.RunProgram(program(`{
+		"mocks": [
+			{
+				"url": "http://wayang.test/*",
+				"headers": { "Content-Type": "text/html" },
+				"body": "<html><body></body></html>"
+			}
+		],
+		"steps": [
+			{
+				"action": "navigate",
+				"link": "http://wayang.test/"
+			},
+			{
+				"action": "setCookies",
+				"cookies": [{ "name": "session", "value": "a" }]
+			},
+			{
+				"action": "setStorage",
+				"key": "token",
+				"value": "t"
+			},
+			{
+				"action": "setStorage",
+				"storage": "session",
+				"key": "tab",
+				"value": "1"
+			},
+			{
+				"action": "saveState",
+				"path": "` + filepath.ToSlash(path) + `"
+			},
+			{
+				"action": "clearCookies"
+			},
+			{
+				"action": "clearStorage"
+			},
+			{
+				"action": "clearStorage",
+				"storage": "session"
+			},
+			{
+				"action": "loadState",
+				"path": "` + filepath.ToSlash(path) + `"
+			},
+			{
+				"action": "navigate",
+				"link": "http://wayang.test/again"
+			},
+			{
+				"action": "store",
+				"items": {
+					"cookies": {
+						"action": "getCookies",
+						"name": "session"
+					},
+					"token": {
+						"action": "getStorage",
+						"key": "token"
+					},
+					"tab": {
+						"action": "getStorage",
+						"storage": "session",
+						"key": "tab"
+					}
+				}
+			}
+		]
+	}`))
+	s.Nil(rErr)
+
+	s.Len(run.ENV["cookies"], 1)
+	s.Equal("t", run.ENV["token"])
+	s.Equal(1.0, run.ENV["tab"])
+
+	state := &wayang.State{}
+	kit.E(kit.ReadJSON(path, state))
+	s.Equal("http://wayang.test", state.Origins[0].Origin)
+	s.Equal(map[string]string{"tab": "1"}, state.Origins[0].SessionStorage)
+}
+
+func (s *S) TestDialogs() {
+	run := s.runner()
+	defer run.Unroute(&wayang.Route{})
+
+	_, err := run.RunProgram(program(`{
+		"mocks": [
+			{
+				"url": "http://wayang.test/",
+				"headers": { "Content-Type": "text/html" },
+				"body": "<html><body><button onclick=\"document.title = prompt('name?', 'none')\">ask</button></body></html>"
+			}
+		],
+		"dialogs": {
+			"handle": "accept",
+			"promptText": "wayang"
+		},
+		"steps": [
+			{
+				"action": "navigate",
+				"link": "http://wayang.test/"
+			},
+			{
+				"action": "store",
+				"items": {
+					"prompt": {
+						"action": "waitDialog",
+						"duration": 3,
+						"statement": {
+							"action": "click",
+							"element": "//button"
+						}
+					}
+				}
+			},
+			{
+				"action": "store",
+				"items": {
+					"accepted": {
+						"action": "eval",
+						"expression": "() => document.title"
+					}
+				}
+			},
+			{
+				"action": "store",
+				"items": {
+					"handled": {
+						"action": "handleDialog",
+						"accept": false,
+						"duration": 3,
+						"statement": {
+							"action": "eval",
+							"expression": "() => String(confirm('sure?'))"
+						}
+					}
+				}
+			}
+		]
+	}`))
+	s.Nil(err)
+
+	s.Equal(map[string]interface{}{
+		"type":          "prompt",
+		"message":       "name?",
+		"defaultPrompt": "none",
+		"url":           "http://wayang.test/",
+	}, run.ENV["prompt"])
+	s.Equal(`"wayang"`, run.ENV["accepted"])
+	s.Equal("sure?", run.ENV["handled"].(map[string]interface{})["message"])
+
+	res, _ := run.RunAction(action(
+		"action", "eval",
+		"expression", "() => confirm('dismissed by default')",
+	))
+	s.Equal("false", res)
+}
+
+func (s *S) TestWaitDownload() {
+	dir, err := ioutil.TempDir("", "wayang")
+	kit.E(err)
+	defer os.RemoveAll(dir)
+
+	run := s.runner()
+	run.ArtifactsDir = dir
+	defer run.Unroute(&wayang.Route{})
+
+	res, rErr := run.RunProgram(program(`{
+		"mocks": [
+			{
+				"url": "http://wayang.test/",
+				"headers": { "Content-Type": "text/html" },
+				"body": "<html><body><a href='/files/report.csv'>export</a></body></html>"
+			},
+			{
+				"url": "http://wayang.test/files/report.csv",
+				"headers": { "Content-Type": "text/csv", "Content-Disposition": "attachment" },
+				"body": "a,b"
+			}
+		],
+		"steps": [
+			{
+				"action": "navigate",
+				"link": "http://wayang.test/"
+			},
+			{
+				"action": "waitDownload",
+				"hash": "md5",
+				"duration": 5,
+				"statement": {
+					"action": "click",
+					"element": "//a"
+				}
+			}
+		]
+	}`))
+	s.Nil(rErr)
+
+	download := res.(map[string]interface{})
+	s.Equal("report.csv", download["filename"])
+	s.Equal(3.0, download["size"])
+	s.Equal("http://wayang.test/files/report.csv", download["url"])
+	s.Equal("b345e1dc09f20fdefdea469f09167892", download["hash"])
+
+	bin, err := ioutil.ReadFile(filepath.Join(dir, "downloads", "report.csv"))
+	kit.E(err)
+	s.Equal("a,b", string(bin))
+
+	// the name comes from the Content-Disposition header, and doesn't replace the earlier download
+	res, rErr = run.RunProgram(program(`{
+		"mocks": [
+			{
+				"url": "http://wayang.test/",
+				"headers": { "Content-Type": "text/html" },
+				"body": "<html><body><a href='/download?id=3'>export</a></body></html>"
+			},
+			{
+				"url": "http://wayang.test/download?id=3",
+				"headers": { "Content-Type": "text/csv", "Content-Disposition": "attachment; filename=\"report.csv\"" },
+				"body": "c,d"
+			}
+		],
+		"steps": [
+			{
+				"action": "navigate",
+				"link": "http://wayang.test/"
+			},
+			{
+				"action": "waitDownload",
+				"duration": 5,
+				"statement": {
+					"action": "click",
+					"element": "//a"
+				}
+			}
+		]
+	}`))
+	s.Nil(rErr)
+	s.Equal("report (1).csv", res.(map[string]interface{})["filename"])
+
+	bin, err = ioutil.ReadFile(filepath.Join(dir, "downloads", "report (1).csv"))
+	kit.E(err)
+	s.Equal("c,d", string(bin))
+	bin, err = ioutil.ReadFile(filepath.Join(dir, "downloads", "report.csv"))
+	kit.E(err)
+	s.Equal("a,b", string(bin))
+}
+
+func (s *S) TestEmulate() {
+	run := s.runner()
+	defer s.page.Viewport(800, 600, 1, false)
+	defer run.RunAction(action("action", "emulate", "reset", true))
+
+	_, err := run.RunProgram(program(`{
+		"device": "iPhone X",
+		"steps": [
+			{
+				"action": "store",
+				"items": {
+					"phone": {
+						"action": "eval",
+						"expression": "() => [innerWidth, devicePixelRatio, navigator.maxTouchPoints > 0, /iPhone/.test(navigator.userAgent)].join()"
+					}
+				}
+			},
+			{
+				"action": "emulate",
+				"device": "Laptop",
+				"width": 1000
+			},
+			{
+				"action": "store",
+				"items": {
+					"laptop": {
+						"action": "eval",
+						"expression": "() => [innerWidth, innerHeight, navigator.maxTouchPoints > 0].join()"
+					}
+				}
+			}
+		]
+	}`))
+	s.Nil(err)
+	s.Equal(`"375,3,true,true"`, run.ENV["phone"])
+	s.Equal(`"1000,768,false"`, run.ENV["laptop"])
+
+	// a flag that is false turns off the one of the preset
+	res, err := run.RunActions([]wayang.Action{
+		{"action": "emulate", "device": "iPad", "touch": false},
+		{"action": "eval", "expression": "() => [innerWidth, navigator.maxTouchPoints > 0].join()"},
+	})
+	s.Nil(err)
+	s.Equal(`"768,false"`, res)
+
+	_, err = run.RunAction(action("action", "emulate", "device", "Nokia 3310"))
+	s.NotNil(err)
+}
+
+func (s *S) TestEnvironment() {
+	run := s.runner()
+	defer run.RunAction(action("action", "emulate", "reset", true))
+
+	_, err := run.RunProgram(program(`{
+		"environment": {
+			"timezone": "Asia/Tokyo",
+			"locale": "de-DE",
+			"colorScheme": "dark"
+		},
+		"steps": [
+			{
+				"action": "emulate",
+				"reducedMotion": "reduce",
+				"geolocation": { "latitude": 0, "longitude": 13.4 }
+			},
+			{
+				"action": "store",
+				"items": {
+					"env": {
+						"action": "eval",
+						"expression": "() => [Intl.DateTimeFormat().resolvedOptions().timeZone, navigator.language, matchMedia('(prefers-color-scheme: dark)').matches, matchMedia('(prefers-reduced-motion: reduce)').matches].join()"
+					}
+				}
+			}
+		]
+	}`))
+	s.Nil(err)
+	s.Equal(`"Asia/Tokyo,de-DE,true,true"`, run.ENV["env"])
+
+	_, err = run.RunAction(action("action", "emulate", "colorScheme", "blue"))
+	s.NotNil(err)
+}
+
+func (s *S) TestNetworkConditions() {
+	run := s.runner()
+	defer run.Unroute(&wayang.Route{})
+
+	_, err := run.RunProgram(program(`{
+		"mocks": [
+			{
+				"url": "http://wayang.test/*",
+				"headers": { "Content-Type": "text/html" },
+				"body": "<html><body></body></html>"
+			}
+		],
+		"steps": [
+			{
+				"action": "navigate",
+				"link": "http://wayang.test/"
+			},
+			{
+				"action": "disableCache"
+			},
+			{
+				"action": "blockURLs",
+				"urls": ["*/ads/*"]
+			},
+			{
+				"action": "throttle",
+				"preset": "fast3g"
+			},
+			{
+				"action": "store",
+				"items": {
+					"blocked": {
+						"action": "eval",
+						"expression": "() => fetch('/ads/banner').then(() => 'ok', () => 'failed')"
+					}
+				}
+			},
+			{
+				"action": "setOffline"
+			},
+			{
+				"action": "store",
+				"items": {
+					"offline": {
+						"action": "eval",
+						"expression": "() => navigator.onLine"
+					}
+				}
+			},
+			{
+				"action": "resetNetwork"
+			},
+			{
+				"action": "store",
+				"items": {
+					"online": {
+						"action": "eval",
+						"expression": "() => fetch('/ads/banner').then(() => navigator.onLine, () => 'failed')"
+					}
+				}
+			}
+		]
+	}`))
+	s.Nil(err)
+	s.Equal(`"failed"`, run.ENV["blocked"])
+	s.Equal("false", run.ENV["offline"])
+	s.Equal("true", run.ENV["online"])
+
+	_, err = run.RunAction(action("action", "throttle", "preset", "dial-up"))
+	s.NotNil(err)
+}
+
+func (s *S) TestParseProgram() {
+	yaml := `
+selectors:
+  title: //h1
+# a comment
+steps:
+  - action: text
+    element: $title
+    duration: 2
+`
+	fromYAML, err := wayang.ParseProgram([]byte(yaml), "program.yml")
+	s.Nil(err)
+
+	fromJSON, err := wayang.ParseProgram([]byte(`{
+		"selectors": { "title": "//h1" },
+		"steps": [{ "action": "text", "element": "$title", "duration": 2 }]
+	}`), "")
+	s.Nil(err)
+
+	s.Equal(fromJSON, fromYAML)
+
+	detected, err := wayang.ParseProgram([]byte(yaml), "")
+	s.Nil(err)
+	s.Equal(fromJSON, detected)
+
+	_, err = wayang.ParseProgram([]byte(yaml), "program.json")
+	s.NotNil(err)
+
+	for _, empty := range []string{"", " \n", "# a comment\n", "null"} {
+		_, err = wayang.ParseProgram([]byte(empty), "")
+		s.EqualError(err, "the program is empty", empty)
+	}
+}
+
+func (s *S) TestImports() {
+	dir, err := ioutil.TempDir("", "wayang")
+	kit.E(err)
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		kit.E(os.MkdirAll(filepath.Dir(path), 0755))
+		kit.E(ioutil.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	write("common/heading.yml", `
+selectors:
+  title: //h4
+actions:
+  title:
+    action: text
+    element: $title
+`)
+	write("lib.json", `{
+		"imports": ["common/heading.yml"],
+		"selectors": { "button": "//button" },
+		"actions": {
+			"click": { "action": "click", "element": "$button" },
+			"heading": { "action": "$heading.title" }
+		}
+	}`)
+	main := write("main.json", `{
+		"imports": [{ "path": "lib.json", "as": "ui" }],
+		"steps": [
+			{ "action": "$ui.click" },
+			{ "action": "$ui.heading" }
+		]
+	}`)
+
+	p, err := wayang.ReadProgram(main)
+	s.Nil(err)
+	s.Nil(p.Imports)
+	s.Equal("//h4", p.Selectors["ui.heading.title"])
+	s.Equal("$ui.button", p.Actions["ui.click"]["element"])
+
+	s.page.Navigate(srcFile("fixtures/click.html"))
+	res, rErr := s.runner().RunProgram(p)
+	s.Nil(rErr)
+	s.Equal("Title", res)
+
+	write("a.json", `{ "imports": ["b.json"] }`)
+	write("b.json", `{ "imports": ["a.json"] }`)
+	_, err = wayang.ReadProgram(filepath.Join(dir, "a.json"))
+	s.Contains(err.Error(), "import cycle")
+
+	write("same.json", `{ "imports": ["lib.json", "common/lib.json"] }`)
+	write("common/lib.json", `{}`)
+	_, err = wayang.ReadProgram(filepath.Join(dir, "same.json"))
+	s.Contains(err.Error(), "the same namespace lib")
+
+	write("conflict.json", `{
+		"imports": [{ "path": "lib.json", "as": "ui" }],
+		"selectors": { "ui.button": "button" }
+	}`)
+	_, err = wayang.ReadProgram(filepath.Join(dir, "conflict.json"))
+	s.Contains(err.Error(), "the selector ui.button imported from lib.json is already defined")
+}
+
+func (s *S) TestIncognito() {
+	run := s.runner()
+	defer run.Unroute(&wayang.Route{})
+	defer run.RunAction(action("action", "clearCookies"))
+
+	_, rErr := run.RunProgram(program(`{
+		"mocks": [
+			{
+				"url": "http://wayang.test/*",
+				"headers": { "Content-Type": "text/html" },
+				"body": "<html><body></body></html>"
+			}
+		],
+		"steps": [
+			{
+				"action": "navigate",
+				"link": "http://wayang.test/"
+			},
+			{
+				"action": "setCookies",
+				"cookies": [{ "name": "session", "value": "a" }]
+			}
+		]
+	}`))
+	s.Nil(rErr)
+
+	incognito, err := run.Incognito()
+	s.Nil(err)
+
+	res, rErr := incognito.RunAction(action("action", "getCookies"))
+	s.Nil(rErr)
+	s.Len(res, 0)
+
+	incognito.Close()
+
+	res, rErr = run.RunAction(action("action", "getCookies", "name", "session"))
+	s.Nil(rErr)
+	s.Len(res, 1)
+}
+
+func (s *S) TestSteps() {
+	s.page.Navigate(srcFile("fixtures/click.html"))
+
+	dir, err := ioutil.TempDir("", "wayang")
+	kit.E(err)
+	defer os.RemoveAll(dir)
+
+	run := s.runner()
+	run.ArtifactsDir = dir
+	_, rErr := run.RunProgram(program(`{
+		"steps": [
+			{ "action": "screenshot", "path": "page.png" },
+			{ "action": "error", "message": "failed" },
+			{ "action": "text", "element": "//h4" }
+		]
+	}`))
+	s.NotNil(rErr)
+
+	steps := run.Steps()
+	s.Len(steps, 3)
+
+	s.Equal("root[0]", steps[0].Source)
+	s.Equal("screenshot", steps[0].Action)
+	s.Equal(wayang.StepPassed, steps[0].Status)
+	s.Equal([]string{filepath.Join(dir, "page.png")}, steps[0].Screenshots)
+	s.Nil(steps[0].Err)
+
+	s.Equal(wayang.StepFailed, steps[1].Status)
+	s.Equal(rErr, steps[1].Err)
+	s.Greater(int64(steps[1].Duration), int64(0))
+
+	s.Equal(wayang.StepSkipped, steps[2].Status)
+	s.Equal(time.Duration(0), steps[2].Duration)
+}
+
+func (s *S) TestAssert() {
+	s.page.Navigate(srcFile("fixtures/click.html"))
+
+	run := s.runner()
+	_, rErr := run.RunProgram(program(`{
+		"selectors": { "title": "//h4" },
+		"actions": {
+			"title": { "action": "text", "element": "$title" }
+		},
+		"steps": [
+			{
+				"action": "assert",
+				"actual": "$title",
+				"equals": "Title"
+			},
+			{
+				"action": "expect",
+				"statement": { "action": "has", "element": "$title" }
+			},
+			{
+				"action": "store",
+				"items": { "count": 3 }
+			},
+			{
+				"action": "assert",
+				"actual": "$count",
+				"greaterThan": 2,
+				"equals": "3"
+			},
+			{
+				"action": "assert",
+				"actual": { "action": "text", "element": "//button" },
+				"contains": "CLICK",
+				"matches": "^click",
+				"ignoreCase": true
+			},
+			{
+				"action": "eval",
+				"expression": "() => setTimeout(() => document.querySelector('h4').innerText = 'Later', 300)"
+			},
+			{
+				"action": "assert",
+				"actual": "$title",
+				"equals": "Later"
+			}
+		]
+	}`))
+	s.Nil(rErr)
+
+	_, rErr = run.RunProgram(program(`{
+		"steps": [{
+			"action": "assert",
+			"message": "the title is wrong",
+			"actual": { "action": "text", "element": "//h4" },
+			"equals": "Other",
+			"duration": 0.3
+		}]
+	}`))
+	s.NotNil(rErr)
+	failure := rErr.Assertion()
+	s.Equal("equals", failure.Comparison)
+	s.Equal("Other", failure.Expected)
+	s.Equal("Later", failure.Actual)
+	s.Contains(rErr.Error(), `the title is wrong: expected "Later" to equal "Other"`)
+
+	_, rErr = run.RunAction(action(
+		"action", "assert",
+		"statement", action("action", "has", "element", "//h1"),
+		"duration", 0.0,
+	))
+	s.NotNil(rErr)
+	s.Equal(false, rErr.Assertion().Actual)
+
+	// a duration of 0 still checks the page once
+	_, rErr = run.RunProgram(program(`{
+		"steps": [
+			{
+				"action": "assert",
+				"actual": { "action": "text", "element": "//h4" },
+				"equals": "Later",
+				"duration": 0
+			},
+			{
+				"action": "assert",
+				"statement": { "action": "has", "element": "//h4" },
+				"duration": 0
+			}
+		]
+	}`))
+	s.Nil(rErr)
+}
+
+func (s *S) TestAssertRoutes() {
+	run := s.runner()
+	defer run.Unroute(&wayang.Route{})
+
+	// the request starts during the check of the assertion, and is fulfilled after it
+	_, rErr := run.RunProgram(program(`{
+		"mocks": [
+			{
+				"url": "http://wayang.test/",
+				"headers": { "Content-Type": "text/html" },
+				"body": "<html><body></body></html>"
+			},
+			{ "url": "*/slow", "delay": 0.5, "body": "done" }
+		],
+		"steps": [
+			{
+				"action": "navigate",
+				"link": "http://wayang.test/"
+			},
+			{
+				"action": "assert",
+				"actual": {
+					"action": "eval",
+					"expression": "() => { fetch('/slow').then(r => r.text()).then(t => window.slow = t); return 'started' }"
+				},
+				"contains": "started"
+			},
+			{
+				"action": "assert",
+				"actual": { "action": "eval", "expression": "() => window.slow || ''" },
+				"contains": "done",
+				"duration": 3
+			}
+		]
+	}`))
+	s.Nil(rErr)
+}
+
+func (s *S) TestSoftAssertions() {
+	s.page.Navigate(srcFile("fixtures/click.html"))
+
+	run := s.runner()
+	_, rErr := run.RunProgram(program(`{
+		"softAssertions": true,
+		"steps": [
+			{
+				"action": "assert",
+				"actual": { "action": "text", "element": "//h4" },
+				"equals": "Other",
+				"duration": 0
+			},
+			{
+				"action": "assert",
+				"actual": { "action": "text", "element": "//h4" },
+				"equals": "Title"
+			},
+			{
+				"action": "assert",
+				"actual": { "action": "text", "element": "//button" },
+				"contains": "other",
+				"duration": 0
+			},
+			{
+				"action": "text",
+				"element": "//h4"
+			}
+		]
+	}`))
+	s.NotNil(rErr)
+	s.Equal("assertions", rErr.Source())
+	s.Contains(rErr.Error(), "2 soft assertions failed")
+	s.Contains(rErr.Error(), `root[2].assert: expected "click me" to contain "other"`)
+
+	failures := rErr.Failures()
+	s.Len(failures, 2)
+	s.Equal("root[0].assert", failures[0].Source)
+	s.Equal("Other", failures[0].Expected)
+	s.Equal("contains", failures[1].Comparison)
+
+	steps := run.Steps()
+	s.Equal(wayang.StepFailed, steps[0].Status)
+	s.Equal(wayang.StepPassed, steps[1].Status)
+	s.Equal(wayang.StepFailed, steps[2].Status)
+	s.Equal(wayang.StepPassed, steps[3].Status)
+
+	_, rErr = run.RunProgram(program(`{
+		"steps": [
+			{
+				"action": "assert",
+				"actual": "a",
+				"equals": "b",
+				"duration": 0,
+				"soft": true
+			},
+			{
+				"action": "assert",
+				"actual": "c",
+				"equals": "d",
+				"duration": 0
+			},
+			{
+				"action": "text",
+				"element": "//h4"
+			}
+		]
+	}`))
+	s.NotNil(rErr)
+	s.Equal("root[1].assert", rErr.Source())
+	s.Len(rErr.Failures(), 2)
+	s.Equal(wayang.StepSkipped, run.Steps()[2].Status)
+}
+
+func (s *S) TestTextMatches() {
+	s.page.Navigate(srcFile("fixtures/click.html"))
+
+	run := s.runner()
+	res, rErr := run.RunProgram(program(`{
+		"steps": [
+			{
+				"action": "textMatches",
+				"statement": { "action": "text", "element": "//button" },
+				"pattern": "^(?P<verb>\\w+) (me)$",
+				"store": "groups"
+			}
+		]
+	}`))
+	s.Nil(rErr)
+	s.Equal(true, res)
+	s.Equal("click", run.ENV["verb"])
+	s.Equal([]interface{}{"click me", "click", "me"}, run.ENV["groups"])
+
+	res, _ = run.RunAction(action(
+		"action", "textMatches",
+		"statement", action("action", "text", "element", "//h4"),
+		"pattern", "^title$",
+	))
+	s.Equal(false, res)
+
+	res, _ = run.RunAction(action(
+		"action", "textMatches",
+		"statement", action("action", "text", "element", "//h4"),
+		"pattern", "^title$",
+		"ignoreCase", true,
+	))
+	s.Equal(true, res)
+}
+
+func (s *S) TestNumberComparisons() {
+	run := s.runner()
+	defer run.Unroute(&wayang.Route{})
+
+	res, rErr := run.RunProgram(program(`{
+		"mocks": [
+			{
+				"url": "http://wayang.test/*",
+				"headers": { "Content-Type": "text/html" },
+				"body": "<html><body><p id='total'>Total: $1,234.50</p><p id='eu'>1.234,50 €</p></body></html>"
+			}
+		],
+		"selectors": { "total": "//*[@id='total']" },
+		"steps": [
+			{
+				"action": "navigate",
+				"link": "http://wayang.test/"
+			},
+			{
+				"action": "store",
+				"items": {
+					"greater": {
+						"action": "greaterThan",
+						"statement": { "action": "text", "element": "$total" },
+						"value": 1000
+					},
+					"less": {
+						"action": "lessThan",
+						"statement": { "action": "text", "element": "$total" },
+						"value": "1,000"
+					},
+					"equal": {
+						"action": "numberEqual",
+						"statement": { "action": "text", "element": "//*[@id='eu']" },
+						"value": 1234.5
+					}
+				}
+			},
+			{
+				"action": "assert",
+				"actual": { "action": "text", "element": "$total" },
+				"lessThan": 1234.51,
+				"greaterThan": 1234.49
+			}
+		]
+	}`))
+	s.Nil(rErr)
+	s.Nil(res)
+	s.Equal(true, run.ENV["greater"])
+	s.Equal(false, run.ENV["less"])
+	s.Equal(true, run.ENV["equal"])
+
+	_, rErr = run.RunAction(action(
+		"action", "greaterThan",
+		"statement", action("action", "text", "element", "//body"),
+		"value", "none",
+	))
+	s.NotNil(rErr)
+
+	numbers := map[string]float64{
+		"0.125":      0.125,
+		"-0.500":     -0.5,
+		"0,75":       0.75,
+		"1.234":      1234,
+		"1 234 567":  1234567,
+		"1'234.50":   1234.5,
+		"1.234.567":  1234567,
+		"10\n20":     10,
+		"12 items":   12,
+		"1,2345":     1.2345,
+		"1.234,50 €": 1234.5,
+	}
+	for text, number := range numbers {
+		_, rErr = run.RunAction(action(
+			"action", "assert",
+			"actual", text,
+			"greaterThan", number-0.001,
+			"lessThan", number+0.001,
+			"duration", 0.0,
+		))
+		s.Nil(rErr, text)
+	}
+
+	for _, text := range []string{"1.2.3", "3 12.50", "12,34.56", "1234,567", "0.125.000"} {
+		_, rErr = run.RunAction(action("action", "assert", "actual", text, "greaterThan", 0, "duration", 0.0))
+		s.NotNil(rErr, text)
+		s.Contains(rErr.Error(), "expected the actual value to be a number", text)
+	}
+}
+
+func (s *S) TestAndOr() {
+	s.page.Navigate(srcFile("fixtures/click.html"))
+
+	has := action("action", "has", "element", "//h4")
+	missing := action("action", "has", "element", "//h1")
+
+	res, _ := s.singleAction(action("action", "and", "statements", []interface{}{has, true}))
+	s.Equal(true, res)
+
+	res, _ = s.singleAction(action("action", "and", "statements", []interface{}{has, missing}))
+	s.Equal(false, res)
+
+	// the statements after the first true one don't run
+	res, _ = s.singleAction(action("action", "or", "statements", []interface{}{
+		missing, has, action("action", "error", "message", "not run"),
+	}))
+	s.Equal(true, res)
+
+	res, _ = s.singleAction(action("action", "or", "statements", []interface{}{false, missing}))
+	s.Equal(false, res)
+
+	_, rErr := s.singleAction(action("action", "or", "statements", []interface{}{"yes"}))
+	s.NotNil(rErr)
+}
+
+func (s *S) TestElementLists() {
+	run := s.runner()
+	defer run.Unroute(&wayang.Route{})
+
+	_, rErr := run.RunProgram(program(`{
+		"mocks": [
+			{
+				"url": "http://wayang.test/*",
+				"headers": { "Content-Type": "text/html" },
+				"body": "<html><body><ul><li data-id='1'>a</li><li data-id='2'>b</li><li>c</li></ul></body></html>"
+			}
+		],
+		"selectors": { "items": "//li" },
+		"steps": [
+			{
+				"action": "navigate",
+				"link": "http://wayang.test/"
+			},
+			{
+				"action": "store",
+				"items": {
+					"count": { "action": "count", "element": "$items" },
+					"none": { "action": "count", "element": "//p" },
+					"texts": { "action": "texts", "element": "$items" },
+					"ids": { "action": "attributes", "element": "$items", "name": "data-id" }
+				}
+			}
+		]
+	}`))
+	s.Nil(rErr)
+	s.Equal(3.0, run.ENV["count"])
+	s.Equal(0.0, run.ENV["none"])
+	s.Equal([]interface{}{"a", "b", "c"}, run.ENV["texts"])
+	s.Equal([]interface{}{"1", "2", nil}, run.ENV["ids"])
+}
+
+func (s *S) TestTable() {
+	run := s.runner()
+	defer run.Unroute(&wayang.Route{})
+
+	res, rErr := run.RunProgram(program(`{
+		"mocks": [
+			{
+				"url": "http://wayang.test/*",
+				"headers": { "Content-Type": "text/html" },
+				"body": "<html><body><table><thead><tr><th>Name</th><th>Price</th><th></th></tr></thead><tbody><tr><td>Tea</td><td>$3.50</td><td>x</td></tr><tr><td colspan='2'>None</td></tr></tbody></table></body></html>"
+			}
+		],
+		"steps": [
+			{
+				"action": "navigate",
+				"link": "http://wayang.test/"
+			},
+			{
+				"action": "table",
+				"element": "//table"
+			}
+		]
+	}`))
+	s.Nil(rErr)
+	s.Equal([]interface{}{
+		map[string]interface{}{"Name": "Tea", "Price": "$3.50", "column 3": "x"},
+		map[string]interface{}{"Name": "None", "Price": "None"},
+	}, res)
+}
+
+func (s *S) TestExtract() {
+	run := s.runner()
+	defer run.Unroute(&wayang.Route{})
+
+	res, rErr := run.RunProgram(program(`{
+		"mocks": [
+			{
+				"url": "http://wayang.test/*",
+				"headers": { "Content-Type": "text/html" },
+				"body": "<html><body><div class='card'><h3>Tea</h3><a href='/tea'>more</a><span>$3.50</span><time>2020-05-01</time><button disabled>buy</button><em>yes</em><ul><li data-stars='5'><b>Ann</b></li><li data-stars='4'><b>Bob</b></li></ul></div><div class='card'><h3>Coffee</h3><a href='/coffee'>more</a><span>soon</span><button>buy</button><em></em><ul></ul></div></body></html>"
+			}
+		],
+		"steps": [
+			{
+				"action": "navigate",
+				"link": "http://wayang.test/"
+			},
+			{
+				"action": "extract",
+				"element": "//div[@class='card']",
+				"fields": {
+					"title": ".//h3",
+					"url": { "sel": ".//a/@href" },
+					"price": { "sel": ".//span", "type": "number", "default": 0 },
+					"released": { "sel": ".//time", "type": "date", "optional": true },
+					"soldOut": { "sel": ".//button", "attr": "disabled", "type": "bool" },
+					"reviews": {
+						"sel": ".//li",
+						"list": true,
+						"fields": {
+							"author": ".//b",
+							"stars": { "attr": "data-stars", "type": "number" }
+						}
+					}
+				}
+			}
+		]
+	}`))
+	s.Nil(rErr)
+	s.Equal([]interface{}{
+		map[string]interface{}{
+			"title":    "Tea",
+			"url":      "/tea",
+			"price":    3.5,
+			"released": "2020-05-01T00:00:00Z",
+			"soldOut":  true,
+			"reviews": []interface{}{
+				map[string]interface{}{"author": "Ann", "stars": 5.0},
+				map[string]interface{}{"author": "Bob", "stars": 4.0},
+			},
+		},
+		map[string]interface{}{
+			"title":    "Coffee",
+			"url":      "/coffee",
+			"price":    0.0,
+			"released": nil,
+			"soldOut":  false,
+			"reviews":  []interface{}{},
+		},
+	}, res)
+
+	_, rErr = run.RunProgram(program(`{
+		"steps": [
+			{
+				"action": "extract",
+				"element": "//div[@class='card']",
+				"fields": {
+					"released": { "sel": ".//time", "type": "date" }
+				}
+			}
+		]
+	}`))
+	s.NotNil(rErr)
+	s.Contains(rErr.Error(), "[1].released is missing")
+
+	// unlike an attribute, an empty text isn't true
+	_, rErr = run.RunProgram(program(`{
+		"steps": [
+			{
+				"action": "extract",
+				"element": "//div[@class='card']",
+				"fields": {
+					"featured": { "sel": ".//em", "type": "bool" }
+				}
+			}
+		]
+	}`))
+	s.NotNil(rErr)
+	s.Contains(rErr.Error(), `[1].featured: could not parse "" as a bool`)
+
+	// an object whose nested field fails falls back to its default, or is null when it is optional
+	res, rErr = run.RunProgram(program(`{
+		"steps": [
+			{
+				"action": "extract",
+				"element": "//div[@class='card']",
+				"fields": {
+					"release": {
+						"optional": true,
+						"fields": { "date": { "sel": ".//time", "type": "date" } }
+					},
+					"price": {
+						"default": { "amount": 0 },
+						"fields": { "amount": { "sel": ".//span", "type": "number" } }
+					}
+				}
+			}
+		]
+	}`))
+	s.Nil(rErr)
+	s.Equal([]interface{}{
+		map[string]interface{}{
+			"release": map[string]interface{}{"date": "2020-05-01T00:00:00Z"},
+			"price":   map[string]interface{}{"amount": 3.5},
+		},
+		map[string]interface{}{
+			"release": nil,
+			"price":   map[string]interface{}{"amount": 0.0},
+		},
+	}, res)
+}
+
+func (s *S) TestPaginate() {
+	run := s.runner()
+	defer run.Unroute(&wayang.Route{})
+
+	res, rErr := run.RunProgram(program(`{
+		"mocks": [
+			{
+				"url": "http://wayang.test/1",
+				"headers": { "Content-Type": "text/html" },
+				"body": "<html><body><ul><li>a</li><li>b</li></ul><a rel='next' href='/2'>next</a></body></html>"
+			},
+			{
+				"url": "http://wayang.test/2",
+				"headers": { "Content-Type": "text/html" },
+				"body": "<html><body><ul><li>c</li></ul><a rel='next' href='/2'>next</a></body></html>"
+			},
+			{
+				"url": "http://wayang.test/list",
+				"headers": { "Content-Type": "text/html" },
+				"body": "<html><body><ul><li>item 1</li></ul><button onclick='next()'>next</button><script>let page = 1; function next() { setTimeout(() => { page++; document.querySelector('ul').innerHTML = '<li>item ' + page + '</li>'; document.querySelector('button').disabled = page === 3 }, 100) }</script></body></html>"
+			}
+		],
+		"steps": [
+			{
+				"action": "navigate",
+				"link": "http://wayang.test/1"
+			},
+			{
+				"action": "paginate",
+				"nextLink": "//a[@rel='next']",
+				"statement": {
+					"action": "texts",
+					"element": "//li"
+				}
+			}
+		]
+	}`))
+	s.Nil(rErr)
+	s.Equal([]interface{}{"a", "b", "c"}, res)
+
+	res, rErr = run.RunProgram(program(`{
+		"steps": [
+			{
+				"action": "navigate",
+				"link": "http://wayang.test/list"
+			},
+			{
+				"action": "paginate",
+				"next": "//button",
+				"statement": {
+					"action": "text",
+					"element": "//li"
+				}
+			}
+		]
+	}`))
+	s.Nil(rErr)
+	s.Equal([]interface{}{"item 1", "item 2", "item 3"}, res)
+
+	res, rErr = run.RunProgram(program(`{
+		"steps": [
+			{
+				"action": "navigate",
+				"link": "http://wayang.test/list"
+			},
+			{
+				"action": "paginate",
+				"next": "//button",
+				"maxPages": 3,
+				"stop": {
+					"action": "has",
+					"element": "//li[text()='item 2']"
+				},
+				"statement": {
+					"action": "text",
+					"element": "//li"
+				}
+			}
+		]
+	}`))
+	s.Nil(rErr)
+	s.Equal([]interface{}{"item 1", "item 2"}, res)
+
+	// the list is cleared at once, and the next results are fetched slowly
+	res, rErr = run.RunProgram(program(`{
+		"mocks": [
+			{
+				"url": "http://wayang.test/fetch",
+				"headers": { "Content-Type": "text/html" },
+				"body": "<html><body><ul><li>slow 1</li></ul><button onclick='next()'>next</button><script>let page = 1; function next() { page++; document.querySelector('ul').innerHTML = ''; fetch('/items?page=' + page).then(r => r.text()).then(html => { document.querySelector('ul').innerHTML = html; document.querySelector('button').disabled = page === 3 }) }</script></body></html>"
+			},
+			{ "url": "*/items?page=2", "delay": 0.6, "body": "<li>slow 2</li>" },
+			{ "url": "*/items?page=3", "delay": 0.6, "body": "<li>slow 3</li>" }
+		],
+		"steps": [
+			{
+				"action": "navigate",
+				"link": "http://wayang.test/fetch"
+			},
+			{
+				"action": "paginate",
+				"next": "//button",
+				"duration": 5,
+				"statement": {
+					"action": "texts",
+					"element": "//li"
+				}
+			}
+		]
+	}`))
+	s.Nil(rErr)
+	s.Equal([]interface{}{"slow 1", "slow 2", "slow 3"}, res)
+}
+
+func (s *S) TestScrollIntoView() {
+	s.page.Navigate(srcFile("fixtures/input.html"))
+
+	res, _ := s.singleAction(action(
+		"action", "scrollIntoView",
+		"element", "//form",
+	))
+	s.Nil(res)
+}
+
+func (s *S) TestScreenshot() {
+	s.page.Navigate(srcFile("fixtures/click.html"))
+
+	res, _ := s.singleAction(action(
+		"action", "screenshot",
+		"element", "//button",
+	))
+	bin, err := base64.StdEncoding.DecodeString(res.(string))
+	s.Nil(err)
+	s.Equal("\x89PNG", string(bin[:4]))
+
+	dir, err := ioutil.TempDir("", "wayang")
+	kit.E(err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "page.jpeg")
+
+	res, _ = s.singleAction(action(
+		"action", "screenshot",
+		"format", "jpeg",
+		"quality", 50.0,
+		"fullPage", true,
+		"path", path,
+	))
+	s.Equal(path, res)
+	s.FileExists(path)
+}
+
+func (s *S) TestMatchScreenshot() {
+	s.page.Navigate(srcFile("fixtures/click.html"))
+
+	dir, err := ioutil.TempDir("", "wayang")
+	kit.E(err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	run := &wayang.Runner{
+		B:            s.browser,
+		P:            s.page,
+		Logger:       s.Logger,
+		ArtifactsDir: dir,
+		SnapshotsDir: filepath.Join(dir, "snapshots"),
+	}
+	match := action(
+		"action", "matchScreenshot",
+		"name", "click",
+		"ignore", []interface{}{"//h4"},
+	)
+
+	_, rErr := run.RunAction(match)
+	s.Nil(rErr)
+	s.FileExists(filepath.Join(dir, "snapshots", "click.png"))
+
+	s.page.ElementX("//h4").Eval("() => this.innerText = 'Changed'")
+
+	_, rErr = run.RunAction(match)
+	s.Nil(rErr)
+
+	delete(match, "ignore")
+	_, rErr = run.RunAction(match)
+	s.NotNil(rErr)
+	s.FileExists(filepath.Join(dir, "click.diff.png"))
+
+	run.UpdateSnapshots = true
+	_, rErr = run.RunAction(match)
+	s.Nil(rErr)
+
+	run.UpdateSnapshots = false
+	_, rErr = run.RunAction(match)
+	s.Nil(rErr)
+
+	match["tolerance"] = 256.0
+	_, rErr = run.RunAction(match)
+	s.NotNil(rErr)
+	s.Contains(rErr.Error(), "the 'tolerance' value must be between 0 and 255")
 }
 
 func (s *S) TextSelectAll() {
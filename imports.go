@@ -0,0 +1,152 @@
+package wayang
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Import is a program file whose selectors and actions are merged into a program, under the namespace As.
+// The references between them become "$<As>.<name>". In a program it is either an object, or the path.
+type Import struct {
+	// Path is relative to the directory of the importing program
+	Path string `json:"path"`
+
+	// As is the namespace, the name of the file without its extension when it is empty
+	As string `json:"as"`
+}
+
+// UnmarshalJSON accepts the path as well as an object
+func (i *Import) UnmarshalJSON(bin []byte) error {
+	var path string
+	if err := json.Unmarshal(bin, &path); err == nil {
+		*i = Import{Path: path}
+		return nil
+	}
+
+	type imp Import
+	return json.Unmarshal(bin, (*imp)(i))
+}
+
+func (i Import) namespace() string {
+	if i.As != "" {
+		return i.As
+	}
+	base := filepath.Base(i.Path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// ResolveImports returns the program with the selectors and actions of its imports merged into it,
+// the relative import paths are relative to the dir
+func (program Program) ResolveImports(dir string) (Program, error) {
+	return program.resolveImports(dir, nil)
+}
+
+func (program Program) resolveImports(dir string, stack []string) (Program, error) {
+	if len(program.Imports) == 0 {
+		return program, nil
+	}
+
+	selectors := map[string]string{}
+	for name, selector := range program.Selectors {
+		selectors[name] = selector
+	}
+	actions := map[string]Action{}
+	for name, action := range program.Actions {
+		actions[name] = action
+	}
+
+	namespaces := map[string]string{}
+	for _, imp := range program.Imports {
+		path := imp.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		path, err := filepath.Abs(path)
+		if err != nil {
+			return program, err
+		}
+
+		ns := imp.namespace()
+		if ns == "" || strings.ContainsAny(ns, "$ ") {
+			return program, fmt.Errorf("invalid namespace %q for the import of %s", ns, imp.Path)
+		}
+		if prev, ok := namespaces[ns]; ok {
+			return program, fmt.Errorf("the imports of %s and %s have the same namespace %s, set a different \"as\" for one of them", prev, imp.Path, ns)
+		}
+		namespaces[ns] = imp.Path
+
+		for _, visited := range stack {
+			if visited == path {
+				return program, fmt.Errorf("import cycle: %s", strings.Join(append(stack, path), " -> "))
+			}
+		}
+
+		imported, err := readProgram(path)
+		if err != nil {
+			return program, fmt.Errorf("could not import %s: %w", imp.Path, err)
+		}
+		imported, err = imported.resolveImports(filepath.Dir(path), append(stack, path))
+		if err != nil {
+			return program, err
+		}
+
+		rename := imported.namespaced(ns)
+		for name, selector := range imported.Selectors {
+			if _, ok := selectors[rename[name]]; ok {
+				return program, fmt.Errorf("the selector %s imported from %s is already defined", rename[name], imp.Path)
+			}
+			selectors[rename[name]] = selector
+		}
+		for name, action := range imported.Actions {
+			if _, ok := actions[rename[name]]; ok {
+				return program, fmt.Errorf("the action %s imported from %s is already defined", rename[name], imp.Path)
+			}
+			actions[rename[name]] = renameRefs(action, rename).(Action)
+		}
+	}
+
+	program.Selectors = selectors
+	program.Actions = actions
+	program.Imports = nil
+	return program, nil
+}
+
+// namespaced maps the names of the selectors and actions of the program to their names in the namespace
+func (program Program) namespaced(ns string) map[string]string {
+	rename := map[string]string{}
+	for name := range program.Selectors {
+		rename[name] = ns + "." + name
+	}
+	for name := range program.Actions {
+		rename[name] = ns + "." + name
+	}
+	return rename
+}
+
+// renameRefs returns a copy of the value where the "$name" strings are renamed
+func renameRefs(value interface{}, rename map[string]string) interface{} {
+	switch v := value.(type) {
+	case string:
+		if to, ok := rename[strings.TrimPrefix(v, "$")]; ok && strings.HasPrefix(v, "$") {
+			return "$" + to
+		}
+		return v
+	case Action:
+		return Action(renameRefs(map[string]interface{}(v), rename).(map[string]interface{}))
+	case map[string]interface{}:
+		res := map[string]interface{}{}
+		for key, item := range v {
+			res[key] = renameRefs(item, rename)
+		}
+		return res
+	case []interface{}:
+		res := []interface{}{}
+		for _, item := range v {
+			res = append(res, renameRefs(item, rename))
+		}
+		return res
+	}
+	return value
+}
@@ -3,6 +3,7 @@ package wayang
 import (
 	"context"
 	"log"
+	"time"
 
 	"github.com/go-rod/rod"
 )
@@ -10,9 +11,40 @@ import (
 type Action map[string]interface{}
 
 type Program struct {
-	Selectors map[string]string `json:"selectors"`
-	Actions   map[string]Action `json:"actions"`
-	Steps     []Action          `json:"steps"`
+	Imports     []Import          `json:"imports"`
+	Selectors   map[string]string `json:"selectors"`
+	Actions     map[string]Action `json:"actions"`
+	Steps       []Action          `json:"steps"`
+	Mocks       []Route           `json:"mocks"`
+	Dialogs     *Dialogs          `json:"dialogs"`
+	Device      *Device           `json:"device"`
+	Environment *Environment      `json:"environment"`
+
+	// Tags are the labels that the test command filters programs by
+	Tags []string `json:"tags"`
+
+	// SoftAssertions makes the failed assertions let the program continue, which then fails at the end with all of them
+	SoftAssertions bool `json:"softAssertions"`
+}
+
+// Route matches requests of the page by their url and method, and fulfills, modifies, delays or aborts them.
+type Route struct {
+	URL    string `json:"url"`
+	Regex  string `json:"regex"`
+	Method string `json:"method"`
+
+	Status   int               `json:"status"`
+	Headers  map[string]string `json:"headers"`
+	Body     interface{}       `json:"body"`
+	BodyFile string            `json:"bodyFile"`
+
+	// HAR replays the responses recorded in a HAR file for the requests the route matches
+	HAR string `json:"har"`
+
+	SetHeaders map[string]string `json:"setHeaders"`
+	Delay      float64           `json:"delay"`
+	Abort      bool              `json:"abort"`
+	Reason     string            `json:"reason"`
 }
 
 type Runner struct {
@@ -22,7 +54,58 @@ type Runner struct {
 	Context   context.Context
 	Canceller context.CancelFunc
 	Logger    *log.Logger
-	program   Program
+
+	// ArtifactsDir is the directory that files written by actions, such as screenshots, are relative to.
+	ArtifactsDir string
+
+	// SnapshotsDir is the directory of the baseline screenshots compared by the matchScreenshot action.
+	SnapshotsDir string
+
+	// UpdateSnapshots makes the matchScreenshot action overwrite the baselines instead of comparing with them.
+	UpdateSnapshots bool
+
+	program Program
+	router  *router
+	network *networkLog
+	har     *harRecorder
+	dialogs *dialogLog
+
+	emulated   emulated
+	conditions networkConditions
+
+	// incognito runners own their browser context instead of the browser
+	incognito bool
+
+	// the results of the steps of the last program, and the screenshots written by the running step
+	steps       []StepResult
+	screenshots []string
+
+	// the errors of the soft assertions that failed
+	soft []RuntimeError
+}
+
+// the statuses of a StepResult
+const (
+	StepPassed  = "passed"
+	StepFailed  = "failed"
+	StepSkipped = "skipped"
+)
+
+// StepResult is the outcome of a step of the last program that a runner ran
+type StepResult struct {
+	// Source is where the step is in the program, such as root[2]
+	Source string
+	Action string
+
+	// Status is StepPassed, StepFailed, or StepSkipped for the steps that didn't run
+	Status   string
+	Duration time.Duration
+
+	// Screenshots are the paths of the screenshots that the step wrote
+	Screenshots []string
+
+	// Err is the error of the failed step
+	Err *RuntimeError
 }
 
 type RuntimeError struct {
@@ -31,4 +114,9 @@ type RuntimeError struct {
 	stack  []byte
 	action Action
 	err    interface{}
+
+	snapshot *PageSnapshot
+
+	// the failed assertions of the program
+	failures []*AssertionFailure
 }
@@ -0,0 +1,325 @@
+package wayang
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/cdp"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// networkLog records the requests of the page while a program runs
+type networkLog struct {
+	lock    sync.Mutex
+	entries []*networkEntry
+	byID    map[proto.NetworkRequestID]*networkEntry
+
+	// the page that the requests are recorded on, which their bodies are read from
+	page *rod.Page
+
+	// closed and replaced every time the log changes
+	changed chan struct{}
+}
+
+type networkEntry struct {
+	id       proto.NetworkRequestID
+	request  *proto.NetworkRequest
+	response *proto.NetworkResponse
+	done     bool
+	failure  string
+}
+
+// requestFilter matches the entries of the network log
+type requestFilter struct {
+	url    *regexp.Regexp
+	method string
+	status func(int64) bool
+}
+
+func newNetworkLog() *networkLog {
+	return &networkLog{
+		byID:    map[proto.NetworkRequestID]*networkEntry{},
+		changed: make(chan struct{}),
+	}
+}
+
+// record the requests of the page until the returned function is called
+func (l *networkLog) record(parent *Runner) (stop func()) {
+	l.page = parent.basePage()
+	return parent.eachPageEvent([]proto.Payload{&proto.NetworkEnable{}}, func(e *cdp.Event) {
+		sent := &proto.NetworkRequestWillBeSent{}
+		received := &proto.NetworkResponseReceived{}
+		finished := &proto.NetworkLoadingFinished{}
+		failed := &proto.NetworkLoadingFailed{}
+
+		l.lock.Lock()
+		defer l.lock.Unlock()
+
+		switch {
+		case rod.Event(e, sent):
+			// a redirect reuses the request id, the previous entry is completed by the redirect response
+			if prev, ok := l.byID[sent.RequestID]; ok && sent.RedirectResponse != nil {
+				prev.response = sent.RedirectResponse
+				prev.done = true
+			}
+			entry := &networkEntry{id: sent.RequestID, request: sent.Request}
+			l.byID[sent.RequestID] = entry
+			l.entries = append(l.entries, entry)
+		case rod.Event(e, received):
+			if entry, ok := l.byID[received.RequestID]; ok {
+				entry.response = received.Response
+			}
+		case rod.Event(e, finished):
+			if entry, ok := l.byID[finished.RequestID]; ok {
+				entry.done = true
+			}
+		case rod.Event(e, failed):
+			if entry, ok := l.byID[failed.RequestID]; ok {
+				entry.done = true
+				entry.failure = failed.ErrorText
+			}
+		default:
+			return
+		}
+
+		close(l.changed)
+		l.changed = make(chan struct{})
+	})
+}
+
+// find the entries from the index that satisfy the filter, and the channel that is closed when the log changes
+func (l *networkLog) find(from int, filter *requestFilter, response bool) ([]*networkEntry, <-chan struct{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	list := []*networkEntry{}
+	if from < len(l.entries) {
+		for _, entry := range l.entries[from:] {
+			if filter.match(entry, response) {
+				list = append(list, entry)
+			}
+		}
+	}
+	return list, l.changed
+}
+
+// disposition is the file name of the Content-Disposition header of the last response to the url
+func (l *networkLog) disposition(url string) string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		entry := l.entries[i]
+		if entry.request.URL != url || entry.response == nil {
+			continue
+		}
+		for name, value := range entry.response.Headers {
+			if strings.EqualFold(name, "Content-Disposition") {
+				_, params, err := mime.ParseMediaType(value.String())
+				if err == nil {
+					return params["filename"]
+				}
+			}
+		}
+		return ""
+	}
+	return ""
+}
+
+func (l *networkLog) len() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return len(l.entries)
+}
+
+func (f *requestFilter) match(entry *networkEntry, response bool) bool {
+	if f.method != "" && !strings.EqualFold(f.method, entry.request.Method) {
+		return false
+	}
+	if f.url != nil && !f.url.MatchString(entry.request.URL) {
+		return false
+	}
+	if response && !(entry.done && entry.response != nil) {
+		return false
+	}
+	if f.status != nil && (entry.response == nil || !f.status(entry.response.Status)) {
+		return false
+	}
+	return true
+}
+
+// filter reads the url, regex, method and status keys of the action
+func (ra runtimeAction) filter(act Action) (*requestFilter, *RuntimeError) {
+	glob, _ := act["url"].(string)
+	regex, _ := act["regex"].(string)
+	url, err := compileURL(glob, regex)
+	if err != nil {
+		rErr := ra.err("could not parse the url pattern:", err)
+		return nil, &rErr
+	}
+
+	filter := &requestFilter{url: url}
+	filter.method, _ = act["method"].(string)
+
+	switch status := act["status"].(type) {
+	case nil:
+	case float64:
+		filter.status = func(code int64) bool { return code == int64(status) }
+	case string:
+		// a class of status codes, such as 5xx
+		if len(status) != 3 || !strings.HasSuffix(strings.ToLower(status), "xx") || status[0] < '1' || status[0] > '5' {
+			rErr := ra.err("expected the 'status' key to be a status code or a class such as '5xx', got", status)
+			return nil, &rErr
+		}
+		class := int64(status[0] - '0')
+		filter.status = func(code int64) bool { return code/100 == class }
+	default:
+		rErr := ra.err("expected the 'status' key to be a status code or a class such as '5xx', got", status)
+		return nil, &rErr
+	}
+
+	return filter, nil
+}
+
+func waitRequestAction(ra runtimeAction, act Action) interface{} {
+	entry, err := ra.waitNetwork(act, false)
+	if err != nil {
+		return *err
+	}
+
+	return map[string]interface{}{
+		"url":     entry.request.URL,
+		"method":  entry.request.Method,
+		"headers": headersMap(entry.request.Headers),
+		"body":    parseBody(entry.request.PostData),
+	}
+}
+
+func waitResponseAction(ra runtimeAction, act Action) interface{} {
+	entry, err := ra.waitNetwork(act, true)
+	if err != nil {
+		return *err
+	}
+
+	res := map[string]interface{}{
+		"url":     entry.request.URL,
+		"method":  entry.request.Method,
+		"status":  entry.response.Status,
+		"headers": headersMap(entry.response.Headers),
+		"body":    nil,
+	}
+
+	if entry.failure != "" {
+		return res
+	}
+	body, e := proto.NetworkGetResponseBody{RequestID: entry.id}.Call(ra.runner.network.page)
+	if e != nil {
+		return res
+	}
+	if body.Base64Encoded {
+		bin, e := base64.StdEncoding.DecodeString(body.Body)
+		if e != nil {
+			return ra.err("could not decode the response body:", e)
+		}
+		res["body"] = parseBody(string(bin))
+	} else {
+		res["body"] = parseBody(body.Body)
+	}
+	return res
+}
+
+func noRequestAction(ra runtimeAction, act Action) interface{} {
+	filter, err := ra.filter(act)
+	if err != nil {
+		return *err
+	}
+
+	matched, _ := ra.runner.network.find(0, filter, filter.status != nil)
+	if len(matched) == 0 {
+		return nil
+	}
+
+	list := []string{}
+	for _, entry := range matched {
+		desc := entry.request.Method + " " + entry.request.URL
+		if entry.response != nil {
+			desc = fmt.Sprintf("%s (%d)", desc, entry.response.Status)
+		}
+		list = append(list, desc)
+	}
+	return ra.err(fmt.Sprintf("expected no request to match, but %d did:", len(list)), list)
+}
+
+// waitNetwork waits for a request of the log that matches the filter of the action. If a 'statement'
+// is provided, it is run first and only the requests that start after it will match, otherwise the
+// latest matching request of the whole run is used.
+func (ra runtimeAction) waitNetwork(act Action, response bool) (*networkEntry, *RuntimeError) {
+	run := ra.runner
+
+	filter, err := ra.filter(act)
+	if err != nil {
+		return nil, err
+	}
+
+	from := 0
+	if stmt := run.makeAction(act["statement"]); stmt != nil {
+		from = run.network.len()
+		if res, ok := ra.runAction(*stmt, ra.source).(RuntimeError); ok {
+			return nil, &res
+		}
+	}
+
+	var timeout <-chan time.Time
+	if duration, ok := act["duration"].(float64); ok {
+		timeout = time.After(time.Duration(float64(time.Second) * duration))
+	}
+	ctx := ra.page.GetContext()
+
+	for {
+		matched, changed := run.network.find(from, filter, response)
+		if len(matched) > 0 {
+			if from == 0 {
+				return matched[len(matched)-1], nil
+			}
+			return matched[0], nil
+		}
+
+		select {
+		case <-ctx.Done():
+			rErr := ra.err("context error:", ctx.Err())
+			return nil, &rErr
+		case <-timeout:
+			rErr := ra.err("waited too long for a matching request")
+			return nil, &rErr
+		case <-changed:
+		}
+	}
+}
+
+func headersMap(headers proto.NetworkHeaders) map[string]string {
+	res := map[string]string{}
+	for name, value := range headers {
+		res[name] = value.String()
+	}
+	return res
+}
+
+// parseBody decodes a JSON body, other bodies are returned as they are
+func parseBody(body string) interface{} {
+	if body == "" {
+		return nil
+	}
+
+	var res interface{}
+	if err := json.Unmarshal([]byte(body), &res); err != nil {
+		return body
+	}
+	return res
+}
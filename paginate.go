@@ -0,0 +1,245 @@
+package wayang
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+const (
+	// the default duration that the paginate action waits for the next page to load
+	pageTimeout = 10 * time.Second
+
+	// the interval between the checks of whether the next page is loaded
+	pageInterval = 100 * time.Millisecond
+)
+
+// watchJS records when the DOM changes, so that a page that loads the next results without navigating is noticed.
+// The state is on the window, it is missing once the page navigated.
+const watchJS = `() => {
+	if (window.__wayangPaginate) window.__wayangPaginate.observer.disconnect()
+
+	const state = { changed: 0 }
+	state.observer = new MutationObserver(() => {
+		state.changed = Date.now()
+	})
+	state.observer.observe(document.documentElement, { childList: true, subtree: true, characterData: true })
+	window.__wayangPaginate = state
+}`
+
+// changedJS returns -1 when the page navigated, 1 when the DOM changed, or 0 when it didn't
+const changedJS = `() => {
+	const state = window.__wayangPaginate
+	if (!state) return -1
+	return state.changed ? 1 : 0
+}`
+
+// disabledJS reports whether the next element can't be used, such as the next button of the last page
+const disabledJS = `() => this.disabled === true || this.getAttribute('aria-disabled') === 'true'`
+
+func paginateAction(ra runtimeAction, act Action) interface{} {
+	run := ra.runner
+
+	stmt := run.makeAction(act["statement"])
+	if stmt == nil {
+		return ra.err("a statement is required to be present")
+	}
+
+	var next, nextLink string
+	var ok bool
+	if attrSel, has := act["next"].(string); has {
+		if next, ok = run.sel(attrSel); !ok {
+			return ra.err("could not find a custom selector defined with the specified value")
+		}
+	} else if attrSel, has := act["nextLink"].(string); has {
+		if nextLink, ok = run.sel(attrSel); !ok {
+			return ra.err("could not find a custom selector defined with the specified value")
+		}
+	} else {
+		return ra.err("a 'next' or 'nextLink' key (type string) is required to be present")
+	}
+
+	maxPages, _ := act["maxPages"].(float64)
+	stop := run.makeAction(act["stop"])
+
+	timeout := pageTimeout
+	if duration, ok := act["duration"].(float64); ok {
+		timeout = time.Duration(float64(time.Second) * duration)
+	}
+
+	results := []interface{}{}
+	visited := map[string]bool{}
+	res := ra.runAction(*stmt, ra.source)
+	for page := 1; ; page++ {
+		if _, ok := res.(RuntimeError); ok {
+			return res
+		}
+		switch items := res.(type) {
+		case []interface{}:
+			results = append(results, items...)
+		case nil:
+		default:
+			results = append(results, items)
+		}
+
+		if maxPages > 0 && float64(page) >= maxPages {
+			return results
+		}
+		if stop != nil {
+			res := ra.runAction(*stop, ra.source)
+			toBool, ok := res.(bool)
+			if !ok {
+				if _, ok := res.(RuntimeError); ok {
+					return res
+				}
+				return ra.err("expected stop to return a bool type, got", res)
+			}
+			if toBool {
+				return results
+			}
+		}
+
+		var more bool
+		var rErr *RuntimeError
+		if next != "" {
+			res, more, rErr = ra.clickNext(next, *stmt, res, timeout)
+		} else if more, rErr = ra.followNext(nextLink, visited, timeout); more {
+			res = ra.runAction(*stmt, ra.source)
+		}
+		if rErr != nil {
+			return *rErr
+		}
+		if !more {
+			return results
+		}
+	}
+}
+
+// nextElement returns the first element that the selector matches, or nil when there is none or it is disabled
+func (ra runtimeAction) nextElement(sel string) (*rod.Element, *RuntimeError) {
+	list, err := ra.page.ElementsXE("", sel)
+	if err != nil {
+		rErr := ra.err("could not query the next element:", err)
+		return nil, &rErr
+	}
+	if len(list) == 0 {
+		return nil, nil
+	}
+
+	res, err := list[0].EvalE(true, disabledJS, nil)
+	if err != nil {
+		rErr := ra.err("could not query the next element:", err)
+		return nil, &rErr
+	}
+	if res.Value.Bool() {
+		return nil, nil
+	}
+	return list[0], nil
+}
+
+// clickNext clicks the next element and returns the result of the statement on the next page. It waits until the
+// page navigated and loaded, or until its DOM changed and the statement returns a result that isn't empty and
+// differs from the one of the previous page, for the listings that load the next results without navigating.
+func (ra runtimeAction) clickNext(sel string, stmt Action, previous interface{}, timeout time.Duration) (interface{}, bool, *RuntimeError) {
+	element, rErr := ra.nextElement(sel)
+	if element == nil || rErr != nil {
+		return nil, false, rErr
+	}
+
+	if _, err := ra.page.EvalE(true, "", watchJS, nil); err != nil {
+		rErr := ra.err("could not watch the page for changes:", err)
+		return nil, false, &rErr
+	}
+	if err := element.ClickE(proto.InputMouseButtonLeft); err != nil {
+		rErr := ra.err("could not click the next element:", err)
+		return nil, false, &rErr
+	}
+
+	ctx, cancel := context.WithTimeout(ra.page.GetContext(), timeout)
+	defer cancel()
+	bounded := ra
+	bounded.page = ra.page.Context(ctx, cancel)
+
+	for {
+		// the page can't be evaluated while it navigates, so an error is checked again after the interval
+		res, err := bounded.page.EvalE(true, "", changedJS, nil)
+		if err == nil {
+			switch res.Value.Int() {
+			case -1:
+				if err := bounded.page.WaitLoadE(); err != nil {
+					rErr := ra.err("waited too long for the next page to load:", err)
+					return nil, false, &rErr
+				}
+				return ra.runAction(stmt, ra.source), true, nil
+			case 1:
+				// the statement may fail while the next results load, such as when they are missing
+				res := bounded.runAction(stmt, ra.source)
+				if _, failed := res.(RuntimeError); !failed && !emptyResult(res) && !reflect.DeepEqual(res, previous) {
+					return res, true, nil
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			rErr := ra.err("waited too long for the results to change after clicking the next element")
+			return nil, false, &rErr
+		case <-time.After(pageInterval):
+		}
+	}
+}
+
+// emptyResult reports whether the result of the statement has no results, such as while the next ones load
+func emptyResult(res interface{}) bool {
+	switch res := res.(type) {
+	case nil:
+		return true
+	case string:
+		return res == ""
+	case []interface{}:
+		return len(res) == 0
+	}
+	return false
+}
+
+// followNext navigates to the link of the next element and waits for it to load. A link that was already followed
+// ends the pagination, so that a last page that links to itself doesn't loop.
+func (ra runtimeAction) followNext(sel string, visited map[string]bool, timeout time.Duration) (bool, *RuntimeError) {
+	if len(visited) == 0 {
+		if res, err := ra.page.EvalE(true, "", `() => location.href`, nil); err == nil {
+			visited[res.Value.String()] = true
+		}
+	}
+
+	element, rErr := ra.nextElement(sel)
+	if element == nil || rErr != nil {
+		return false, rErr
+	}
+	res, err := element.EvalE(true, `() => this.href || ''`, nil)
+	if err != nil {
+		rErr := ra.err("could not get the link of the next element:", err)
+		return false, &rErr
+	}
+	link := res.Value.String()
+	if link == "" || visited[link] {
+		return false, nil
+	}
+	visited[link] = true
+
+	ctx, cancel := context.WithTimeout(ra.page.GetContext(), timeout)
+	defer cancel()
+	page := ra.page.Context(ctx, cancel)
+
+	if err := page.NavigateE(link); err != nil {
+		rErr := ra.err("could not navigate to the next page:", err)
+		return false, &rErr
+	}
+	if err := page.WaitLoadE(); err != nil {
+		rErr := ra.err("waited too long for the next page to load:", err)
+		return false, &rErr
+	}
+	return true, nil
+}
@@ -0,0 +1,75 @@
+package wayang
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ReadProgram reads a JSON or YAML program file, see ParseProgram, and resolves its imports relative to the file
+func ReadProgram(path string) (Program, error) {
+	program, err := readProgram(path)
+	if err != nil {
+		return program, err
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return program, err
+	}
+	return program.resolveImports(filepath.Dir(abs), []string{abs})
+}
+
+func readProgram(path string) (Program, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Program{}, err
+	}
+	return ParseProgram(data, path)
+}
+
+// ParseProgram decodes a JSON or YAML program. The name is the file the program comes from, its extension
+// tells the format apart, and without a known extension a program that doesn't start with "{" is YAML.
+// An empty document is an error.
+func ParseProgram(data []byte, name string) (Program, error) {
+	program := Program{}
+
+	if isYAML(data, name) {
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return program, err
+		}
+		if doc == nil {
+			return program, errEmptyProgram
+		}
+		// the decoded YAML goes through JSON, so that both formats fill the program the same way
+		bin, err := json.Marshal(doc)
+		if err != nil {
+			return program, err
+		}
+		data = bin
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return program, errEmptyProgram
+	}
+	err := json.Unmarshal(data, &program)
+	return program, err
+}
+
+var errEmptyProgram = errors.New("the program is empty")
+
+func isYAML(data []byte, name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		return true
+	case ".json":
+		return false
+	}
+	return !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+}
@@ -0,0 +1,288 @@
+package wayang
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/cdp"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// router pauses every request of the page through the Fetch domain, and handles it with the last
+// added route that matches it. The requests that no route matches are continued untouched.
+type router struct {
+	runner *Runner
+	stop   func()
+
+	// the base page of the runner when the router was added, the requests are handled in their own goroutines
+	// while the actions run, and after the page of the actions timed out
+	page *rod.Page
+
+	lock   sync.Mutex
+	routes []*compiledRoute
+}
+
+type compiledRoute struct {
+	*Route
+	origin *Route // the route that was added, it differs from Route for the routes of a HAR file
+	url    *regexp.Regexp
+	body   []byte
+}
+
+// compileURL turns the glob and regex of a route or a request filter into one regexp,
+// in the glob "*" matches any characters, "?" matches a single character and the other characters match
+// themselves. Nil matches every url.
+func compileURL(glob, regex string) (*regexp.Regexp, error) {
+	if regex != "" {
+		return regexp.Compile(regex)
+	}
+	if glob != "" {
+		pattern := strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(regexp.QuoteMeta(glob))
+		return regexp.Compile(`\A` + pattern + `\z`)
+	}
+	return nil, nil
+}
+
+func compileRoute(route *Route) (*compiledRoute, error) {
+	url, err := compileURL(route.URL, route.Regex)
+	if err != nil {
+		return nil, err
+	}
+	// the route of the caller, such as a mock of a program that runs again, is left as it is
+	copied := *route
+	compiled := &compiledRoute{Route: &copied, origin: route, url: url}
+
+	switch body := route.Body.(type) {
+	case nil:
+	case string:
+		compiled.body = []byte(body)
+	default:
+		compiled.body, err = json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		if !hasHeader(route.Headers, "Content-Type") {
+			compiled.Headers = copyHeaders(route.Headers)
+			compiled.Headers["Content-Type"] = "application/json; charset=utf-8"
+		}
+	}
+
+	if route.BodyFile != "" {
+		compiled.body, err = ioutil.ReadFile(route.BodyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return compiled, nil
+}
+
+func (route *compiledRoute) match(url, method string) bool {
+	if route.Method != "" && !strings.EqualFold(route.Method, method) {
+		return false
+	}
+	return route.url == nil || route.url.MatchString(url)
+}
+
+// fulfills reports whether the route responds by itself instead of continuing the request
+func (route *compiledRoute) fulfills() bool {
+	return route.Status != 0 || route.Body != nil || route.BodyFile != ""
+}
+
+// hasHeader tells if the headers have the name, in any case
+func hasHeader(headers map[string]string, name string) bool {
+	for key := range headers {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func copyHeaders(headers map[string]string) map[string]string {
+	res := map[string]string{}
+	for key, value := range headers {
+		res[key] = value
+	}
+	return res
+}
+
+// Route adds a route to the page of the runner, routes added later take precedence.
+// The routes are removed when the runner closes.
+func (parent *Runner) Route(route *Route) error {
+	var compiled []*compiledRoute
+	if route.HAR != "" {
+		list, err := harRoutes(route)
+		if err != nil {
+			return err
+		}
+		compiled = list
+	} else {
+		item, err := compileRoute(route)
+		if err != nil {
+			return err
+		}
+		compiled = []*compiledRoute{item}
+	}
+
+	if parent.router == nil {
+		parent.router = newRouter(parent)
+	}
+
+	parent.router.lock.Lock()
+	defer parent.router.lock.Unlock()
+	parent.router.routes = append(parent.router.routes, compiled...)
+	return nil
+}
+
+// Unroute removes the routes with the same url, regex and method. An empty route removes all of them.
+func (parent *Runner) Unroute(route *Route) {
+	r := parent.router
+	if r == nil {
+		return
+	}
+
+	r.lock.Lock()
+	routes := []*compiledRoute{}
+	for _, item := range r.routes {
+		origin := item.origin
+		if route.URL != "" && origin.URL != route.URL ||
+			route.Regex != "" && origin.Regex != route.Regex ||
+			route.Method != "" && !strings.EqualFold(origin.Method, route.Method) {
+			routes = append(routes, item)
+		}
+	}
+	r.routes = routes
+	r.lock.Unlock()
+
+	if len(routes) == 0 {
+		r.stop()
+		parent.router = nil
+	}
+}
+
+func newRouter(parent *Runner) *router {
+	r := &router{runner: parent, page: parent.basePage()}
+	r.stop = parent.eachPageEvent([]proto.Payload{&proto.FetchEnable{}}, func(e *cdp.Event) {
+		paused := &proto.FetchRequestPaused{}
+		if rod.Event(e, paused) {
+			go r.handle(paused)
+		}
+	})
+	return r
+}
+
+func (r *router) find(e *proto.FetchRequestPaused) *compiledRoute {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for i := len(r.routes) - 1; i >= 0; i-- {
+		if r.routes[i].match(e.Request.URL, e.Request.Method) {
+			return r.routes[i]
+		}
+	}
+	return nil
+}
+
+func (r *router) handle(e *proto.FetchRequestPaused) {
+	page := r.page
+	route := r.find(e)
+
+	var err error
+	switch {
+	case route == nil:
+		err = proto.FetchContinueRequest{RequestID: e.RequestID}.Call(page)
+
+	case route.Abort:
+		r.delay(route)
+		reason := proto.NetworkErrorReason(route.Reason)
+		if reason == "" {
+			reason = proto.NetworkErrorReasonFailed
+		}
+		err = proto.FetchFailRequest{RequestID: e.RequestID, ErrorReason: reason}.Call(page)
+
+	case route.fulfills():
+		r.delay(route)
+		status := route.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		// a header with several values, such as Set-Cookie, has a line for each of them
+		headers := []*proto.FetchHeaderEntry{}
+		for name, value := range route.Headers {
+			for _, line := range strings.Split(value, "\n") {
+				headers = append(headers, &proto.FetchHeaderEntry{Name: name, Value: line})
+			}
+		}
+		err = proto.FetchFulfillRequest{
+			RequestID:       e.RequestID,
+			ResponseCode:    int64(status),
+			ResponseHeaders: headers,
+			Body:            route.body,
+		}.Call(page)
+
+	default:
+		r.delay(route)
+		headers := []*proto.FetchHeaderEntry{}
+		for name, value := range e.Request.Headers {
+			if !hasHeader(route.SetHeaders, name) {
+				headers = append(headers, &proto.FetchHeaderEntry{Name: name, Value: value.String()})
+			}
+		}
+		for name, value := range route.SetHeaders {
+			headers = append(headers, &proto.FetchHeaderEntry{Name: name, Value: value})
+		}
+		err = proto.FetchContinueRequest{RequestID: e.RequestID, Headers: headers}.Call(page)
+	}
+
+	if err != nil {
+		r.runner.Error("could not handle the request to " + e.Request.URL + ": " + err.Error())
+	}
+}
+
+func (r *router) delay(route *compiledRoute) {
+	if route.Delay > 0 {
+		time.Sleep(time.Duration(float64(time.Second) * route.Delay))
+	}
+}
+
+func routeAction(ra runtimeAction, act Action) interface{} {
+	route, err := ra.route(act)
+	if err != nil {
+		return *err
+	}
+
+	if e := ra.runner.Route(route); e != nil {
+		return ra.err("could not add the route:", e)
+	}
+	return nil
+}
+
+func unrouteAction(ra runtimeAction, act Action) interface{} {
+	route, err := ra.route(act)
+	if err != nil {
+		return *err
+	}
+
+	ra.runner.Unroute(route)
+	return nil
+}
+
+func (ra runtimeAction) route(act Action) (*Route, *RuntimeError) {
+	route := &Route{}
+	bin, err := json.Marshal(act)
+	if err == nil {
+		err = json.Unmarshal(bin, route)
+	}
+	if err != nil {
+		rErr := ra.err("could not parse the route:", err)
+		return nil, &rErr
+	}
+	return route, nil
+}
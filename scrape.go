@@ -0,0 +1,429 @@
+package wayang
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-rod/rod"
+)
+
+// tableJS returns the rows of the table as objects keyed by the text of the header cells, the header is the last
+// row of the thead, or the first row without a thead. The cells that span columns are repeated for each of them.
+const tableJS = `() => {
+	const cells = (row) => {
+		const list = []
+		for (const cell of row.cells) {
+			for (let i = 0; i < cell.colSpan; i++) list.push(cell.innerText.trim())
+		}
+		return list
+	}
+
+	const rows = Array.from(this.rows)
+	if (rows.length === 0) return []
+	const head = this.tHead && this.tHead.rows.length ? this.tHead.rows[this.tHead.rows.length - 1] : rows[0]
+
+	const keys = []
+	cells(head).forEach((text, i) => {
+		let key = text || 'column ' + (i + 1)
+		for (let n = 2; keys.includes(key); n++) key = (text || 'column ' + (i + 1)) + ' ' + n
+		keys.push(key)
+	})
+
+	return rows
+		.filter((row) => row !== head && !(this.tHead && this.tHead.contains(row)))
+		.map((row) => {
+			const item = {}
+			cells(row).forEach((text, i) => {
+				if (i < keys.length) item[keys[i]] = text
+			})
+			return item
+		})
+}`
+
+// elements returns every element that the selector of the 'element' key matches, without waiting for them
+func (ra runtimeAction) elements(act Action) (rod.Elements, *RuntimeError) {
+	run := ra.runner
+
+	attrSel, ok := act["element"].(string)
+	if !ok {
+		err := ra.err("an 'element' key (type string) is required to be present")
+		return nil, &err
+	}
+	sel, ok := run.sel(attrSel)
+	if !ok {
+		err := ra.err("could not find a custom selector defined with the specified value")
+		return nil, &err
+	}
+
+	list, err := ra.page.ElementsXE("", sel)
+	if err != nil {
+		rErr := ra.err("could not query the elements:", err)
+		return nil, &rErr
+	}
+	return list, nil
+}
+
+func attributesAction(ra runtimeAction, act Action) interface{} {
+	name, ok := act["name"].(string)
+	if !ok {
+		return ra.err("a 'name' key (type string) is required to be present")
+	}
+	list, rErr := ra.elements(act)
+	if rErr != nil {
+		return *rErr
+	}
+
+	res := []interface{}{}
+	for _, element := range list {
+		attr, err := element.AttributeE(name)
+		if err != nil {
+			return ra.err("could not get the attribute:", err)
+		}
+		if attr == nil {
+			res = append(res, nil)
+			continue
+		}
+		res = append(res, *attr)
+	}
+	return res
+}
+
+func countAction(ra runtimeAction, act Action) interface{} {
+	list, rErr := ra.elements(act)
+	if rErr != nil {
+		return *rErr
+	}
+	return float64(len(list))
+}
+
+func tableAction(ra runtimeAction, act Action) interface{} {
+	element, rErr := ra.createElem(act)
+	if rErr != nil {
+		return *rErr
+	}
+
+	res, err := element.EvalE(true, tableJS, nil)
+	if err != nil {
+		return ra.err("could not read the table:", err)
+	}
+
+	rows := []interface{}{}
+	if err := json.Unmarshal([]byte(res.Value.Raw), &rows); err != nil {
+		return ra.err("could not read the table:", err)
+	}
+	return rows
+}
+
+func textsAction(ra runtimeAction, act Action) interface{} {
+	list, rErr := ra.elements(act)
+	if rErr != nil {
+		return *rErr
+	}
+
+	res := []interface{}{}
+	for _, element := range list {
+		text, err := element.TextE()
+		if err != nil {
+			return ra.err("could not get the text:", err)
+		}
+		res = append(res, text)
+	}
+	return res
+}
+
+// extractJS returns the raw values of the fields for every container that the xpath matches. A field without its
+// own elements is null, and a list field is an array.
+const extractJS = `(xpath, fields) => {
+	const all = (xpath, context) => {
+		const res = document.evaluate(xpath, context, null, XPathResult.ORDERED_NODE_SNAPSHOT_TYPE, null)
+		const list = []
+		for (let i = 0; i < res.snapshotLength; i++) list.push(res.snapshotItem(i))
+		return list
+	}
+
+	const read = (node, attr) => {
+		if (node.nodeType !== Node.ELEMENT_NODE) return node.nodeValue.trim()
+		switch (attr) {
+		case 'text':
+			if (['INPUT', 'TEXTAREA', 'SELECT'].includes(node.tagName)) return node.value
+			return node.innerText.trim()
+		case 'html':
+			return node.outerHTML
+		}
+		return node.getAttribute(attr)
+	}
+
+	const item = (node, fields) => {
+		const res = {}
+		for (const [name, field] of Object.entries(fields)) {
+			const nodes = field.sel ? all(field.sel, node) : [node]
+			const values = nodes.map((n) => field.fields ? item(n, field.fields) : read(n, field.attr))
+			res[name] = field.list ? values : values.length ? values[0] : null
+		}
+		return res
+	}
+
+	return all(xpath, document).map((node) => item(node, fields))
+}`
+
+// extractField is a field of the schema of the extract action, which is either an object or the selector
+type extractField struct {
+	sel      string
+	attr     string
+	kind     string
+	format   string
+	list     bool
+	optional bool
+	fields   map[string]*extractField
+
+	fallback   interface{}
+	hasDefault bool
+}
+
+// attribute tells if the field reads an attribute of its elements rather than their text or html
+func (field *extractField) attribute() bool {
+	return field.attr != "text" && field.attr != "html"
+}
+
+// dateLayouts are tried in order to parse the dates of the fields without a format
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02",
+	"01/02/2006",
+	"02.01.2006",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+	"2 Jan 2006",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+func extractAction(ra runtimeAction, act Action) interface{} {
+	run := ra.runner
+
+	attrSel, ok := act["element"].(string)
+	if !ok {
+		return ra.err("an 'element' key (type string) is required to be present")
+	}
+	sel, ok := run.sel(attrSel)
+	if !ok {
+		return ra.err("could not find a custom selector defined with the specified value")
+	}
+	fields, err := ra.extractFields(act["fields"], "fields")
+	if err != nil {
+		return ra.err(err)
+	}
+
+	res, err := ra.page.EvalE(true, "", extractJS, rod.Array{sel, extractSchema(fields)})
+	if err != nil {
+		return ra.err("could not extract the elements:", err)
+	}
+	items := []interface{}{}
+	if err := json.Unmarshal([]byte(res.Value.Raw), &items); err != nil {
+		return ra.err("could not extract the elements:", err)
+	}
+
+	for i, item := range items {
+		raw, _ := item.(map[string]interface{})
+		items[i], err = convertFields(fields, raw, fmt.Sprintf("[%d]", i))
+		if err != nil {
+			return ra.err(err)
+		}
+	}
+	return items
+}
+
+// extractFields parses the field map of the schema, the path is where it is in the action for the errors
+func (ra runtimeAction) extractFields(value interface{}, path string) (map[string]*extractField, error) {
+	raw, ok := value.(map[string]interface{})
+	if !ok || len(raw) == 0 {
+		return nil, fmt.Errorf("a '%s' key (type map of fields) is required to be present", path)
+	}
+
+	fields := map[string]*extractField{}
+	for name, value := range raw {
+		field, err := ra.extractField(value, path+"."+name)
+		if err != nil {
+			return nil, err
+		}
+		fields[name] = field
+	}
+	return fields, nil
+}
+
+func (ra runtimeAction) extractField(value interface{}, path string) (*extractField, error) {
+	field := &extractField{attr: "text", kind: "string"}
+
+	raw, ok := value.(map[string]interface{})
+	if !ok {
+		sel, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a selector or an object", path)
+		}
+		raw = map[string]interface{}{"sel": sel}
+	}
+
+	if sel, ok := raw["sel"].(string); ok {
+		if field.sel, ok = ra.runner.sel(sel); !ok {
+			return nil, fmt.Errorf("%s.sel: could not find a custom selector defined with the specified value", path)
+		}
+	}
+	if attr, ok := raw["attr"].(string); ok && attr != "" {
+		field.attr = attr
+	}
+	if kind, ok := raw["type"].(string); ok {
+		switch kind {
+		case "string", "number", "bool", "date":
+			field.kind = kind
+		default:
+			return nil, fmt.Errorf("%s.type must be string, number, bool or date", path)
+		}
+	}
+	field.format, _ = raw["format"].(string)
+	field.list, _ = raw["list"].(bool)
+	field.optional, _ = raw["optional"].(bool)
+	field.fallback, field.hasDefault = raw["default"]
+
+	if nested, ok := raw["fields"]; ok {
+		fields, err := ra.extractFields(nested, path+".fields")
+		if err != nil {
+			return nil, err
+		}
+		field.fields = fields
+	}
+	return field, nil
+}
+
+// extractSchema is the part of the fields that extractJS uses
+func extractSchema(fields map[string]*extractField) map[string]interface{} {
+	schema := map[string]interface{}{}
+	for name, field := range fields {
+		item := map[string]interface{}{
+			"sel":  field.sel,
+			"attr": field.attr,
+			"list": field.list,
+		}
+		if field.fields != nil {
+			item["fields"] = extractSchema(field.fields)
+		}
+		schema[name] = item
+	}
+	return schema
+}
+
+// convertFields applies the types, defaults and optional fields of the schema to the raw values of an item
+func convertFields(fields map[string]*extractField, raw map[string]interface{}, path string) (map[string]interface{}, error) {
+	// the fields are converted in order so that the error is the same on every run
+	names := []string{}
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	res := map[string]interface{}{}
+	for _, name := range names {
+		field := fields[name]
+		fieldPath := path + "." + name
+
+		if !field.list {
+			value, err := field.convert(raw[name], fieldPath)
+			if err != nil {
+				return nil, err
+			}
+			res[name] = value
+			continue
+		}
+
+		list := []interface{}{}
+		items, _ := raw[name].([]interface{})
+		for i, item := range items {
+			value, err := field.convert(item, fmt.Sprintf("%s[%d]", fieldPath, i))
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		res[name] = list
+	}
+	return res, nil
+}
+
+// convert a raw value of the field, a missing or invalid value is the default, or null when the field is optional
+func (field *extractField) convert(value interface{}, path string) (interface{}, error) {
+	var res interface{}
+	var err error
+	switch {
+	case value == nil && field.kind == "bool" && field.attribute():
+		// a boolean attribute, such as disabled, is false when it is absent
+		return false, nil
+	case value == nil:
+		err = fmt.Errorf("%s is missing", path)
+	case field.fields != nil:
+		// a nested field that fails falls back like the object does, its error already tells how to avoid it
+		raw, _ := value.(map[string]interface{})
+		if res, err = convertFields(field.fields, raw, path); err != nil && !field.hasDefault && !field.optional {
+			return nil, err
+		}
+	default:
+		res, err = field.coerce(value.(string))
+		if err != nil {
+			err = fmt.Errorf("%s: %s", path, err)
+		}
+	}
+
+	switch {
+	case err == nil:
+		return res, nil
+	case field.hasDefault:
+		return field.fallback, nil
+	case field.optional:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("%s, set a default or make the field optional", err)
+}
+
+// coerce the text to the type of the field
+func (field *extractField) coerce(text string) (interface{}, error) {
+	switch field.kind {
+	case "number":
+		if n, ok := parseNumber(text); ok {
+			return n, nil
+		}
+		return nil, fmt.Errorf("could not parse %q as a number", text)
+
+	case "bool":
+		switch strings.ToLower(strings.TrimSpace(text)) {
+		case "true", "yes", "on", "1":
+			return true, nil
+		case "false", "no", "off", "0":
+			return false, nil
+		case "":
+			// a boolean attribute that is present has an empty value, but an empty text isn't a bool
+			if field.attribute() {
+				return true, nil
+			}
+		}
+		return nil, fmt.Errorf("could not parse %q as a bool", text)
+
+	case "date":
+		layouts := dateLayouts
+		if field.format != "" {
+			layouts = []string{field.format}
+		}
+		for _, layout := range layouts {
+			if t, err := time.Parse(layout, strings.TrimSpace(text)); err == nil {
+				return t.Format(time.RFC3339), nil
+			}
+		}
+		return nil, fmt.Errorf("could not parse %q as a date", text)
+	}
+	return text, nil
+}
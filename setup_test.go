@@ -53,10 +53,23 @@ func (s *S) execute(test string) (interface{}, *wayang.RuntimeError) {
 		P:      s.page,
 		Logger: s.Logger,
 	}
+
+	return parent.RunProgram(program(test))
+}
+
+func (s *S) runner() *wayang.Runner {
+	return &wayang.Runner{
+		B:      s.browser,
+		P:      s.page,
+		ENV:    map[string]interface{}{},
+		Logger: s.Logger,
+	}
+}
+
+func program(test string) wayang.Program {
 	program := wayang.Program{}
 	kit.E(json.Unmarshal([]byte(test), &program))
-
-	return parent.RunProgram(program)
+	return program
 }
 
 func (s *S) singleAction(action wayang.Action) (interface{}, *wayang.RuntimeError) {
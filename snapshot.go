@@ -0,0 +1,89 @@
+package wayang
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/ysmood/kit"
+)
+
+// the longest time spent on capturing the page state after a failure
+const snapshotTimeout = 5 * time.Second
+
+// PageSnapshot is the state of the page at the moment a RuntimeError was returned.
+// Any part that could not be captured is left empty.
+type PageSnapshot struct {
+	URL        string `json:"url"`
+	Title      string `json:"title"`
+	HTML       string `json:"-"`
+	Screenshot []byte `json:"-"`
+}
+
+// Write the snapshot into dir as page.json, page.html and screenshot.png, and returns the written paths.
+func (snap *PageSnapshot) Write(dir string) ([]string, error) {
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"page.json", snap},
+		{"page.html", snap.HTML},
+		{"screenshot.png", snap.Screenshot},
+	}
+
+	paths := []string{}
+	for _, file := range files {
+		if bin, ok := file.data.([]byte); ok && len(bin) == 0 {
+			continue
+		}
+
+		path := filepath.Join(dir, file.name)
+		if err := kit.OutputFile(path, file.data, nil); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// snapshotCaller calls the page session with its own context, so that the page can still be
+// captured after the context of the page is cancelled or timed out.
+type snapshotCaller struct {
+	ctx    context.Context
+	runner *Runner
+}
+
+func (c snapshotCaller) CallContext() (context.Context, proto.Client, string) {
+	return c.ctx, c.runner.B, string(c.runner.P.SessionID)
+}
+
+func (parent *Runner) snapshot() *PageSnapshot {
+	if parent.B == nil || parent.P == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), snapshotTimeout)
+	defer cancel()
+	caller := snapshotCaller{ctx: ctx, runner: parent}
+
+	snap := &PageSnapshot{}
+	if info, err := (proto.TargetGetTargetInfo{TargetID: parent.P.TargetID}).Call(caller); err == nil {
+		snap.URL = info.TargetInfo.URL
+		snap.Title = info.TargetInfo.Title
+	}
+
+	html, err := proto.RuntimeEvaluate{
+		Expression:    "document.documentElement.outerHTML",
+		ReturnByValue: true,
+	}.Call(caller)
+	if err == nil && html.ExceptionDetails == nil {
+		snap.HTML = html.Result.Value.String()
+	}
+
+	if shot, err := (proto.PageCaptureScreenshot{}).Call(caller); err == nil {
+		snap.Screenshot = shot.Data
+	}
+
+	return snap
+}
@@ -0,0 +1,248 @@
+package wayang
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/cdp"
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/ysmood/kit"
+)
+
+// stateMarker is kept in the session storage of a page once the session storage of a state is restored in it,
+// so that it isn't restored again on the following navigations
+const stateMarker = "wayang:state"
+
+// State is the cookies of the browser, and the local and session storage of some origins
+type State struct {
+	Cookies []*proto.NetworkCookie `json:"cookies"`
+	Origins []*OriginState         `json:"origins"`
+}
+
+type OriginState struct {
+	Origin         string            `json:"origin"`
+	LocalStorage   map[string]string `json:"localStorage"`
+	SessionStorage map[string]string `json:"sessionStorage,omitempty"`
+}
+
+// State returns the cookies of the browser, and the storage of the origins.
+// The origin of the current page is used when none is given. The session storage is only
+// available for the origin of the current page, since every page has its own.
+func (parent *Runner) State(origins ...string) (*State, error) {
+	cookies, err := parent.cookies(cookieFilter{})
+	if err != nil {
+		return nil, err
+	}
+	state := &State{Cookies: cookies, Origins: []*OriginState{}}
+
+	current := parent.origin()
+	if len(origins) == 0 && current != "" {
+		origins = []string{current}
+	}
+
+	for _, origin := range origins {
+		item := &OriginState{Origin: origin}
+
+		if origin == current {
+			if item.LocalStorage, err = storageItems(parent.P, "local"); err != nil {
+				return nil, err
+			}
+			if item.SessionStorage, err = storageItems(parent.P, "session"); err != nil {
+				return nil, err
+			}
+			delete(item.SessionStorage, stateMarker)
+		} else {
+			err = parent.withOrigin(origin, func(page *rod.Page) (err error) {
+				item.LocalStorage, err = storageItems(page, "local")
+				return
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		state.Origins = append(state.Origins, item)
+	}
+	return state, nil
+}
+
+// SetState sets the cookies and the local storage of the state. The session storage of an origin is restored
+// in the current page, now if it is on the origin, or else the first time it navigates to the origin.
+func (parent *Runner) SetState(state *State) error {
+	if len(state.Cookies) > 0 {
+		cookies, err := cookieParams(state.Cookies)
+		if err != nil {
+			return err
+		}
+		if err := parent.setCookies(cookies); err != nil {
+			return err
+		}
+	}
+
+	current := parent.origin()
+	for _, item := range state.Origins {
+		var err error
+		switch {
+		case len(item.LocalStorage) == 0:
+		case item.Origin == current:
+			_, err = evalStorage(parent.P, "local", "set", item.LocalStorage)
+		default:
+			err = parent.withOrigin(item.Origin, func(page *rod.Page) error {
+				_, err := evalStorage(page, "local", "set", item.LocalStorage)
+				return err
+			})
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(item.SessionStorage) > 0 {
+			if err := parent.restoreSession(item, item.Origin == current); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SaveState writes the State of the origins to a JSON file
+func (parent *Runner) SaveState(path string, origins ...string) error {
+	state, err := parent.State(origins...)
+	if err != nil {
+		return err
+	}
+	return kit.OutputFile(path, state, nil)
+}
+
+// LoadState reads a JSON file written by SaveState, and sets its State
+func (parent *Runner) LoadState(path string) error {
+	state := &State{}
+	bin, err := ioutil.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(bin, state)
+	}
+	if err != nil {
+		return err
+	}
+	return parent.SetState(state)
+}
+
+// origin returns the origin of the current page, it is empty for pages without one such as about:blank
+func (parent *Runner) origin() string {
+	res, err := parent.P.EvalE(true, "", `() => location.origin`, nil)
+	if err != nil || res.Value.String() == "null" {
+		return ""
+	}
+	return res.Value.String()
+}
+
+// withOrigin calls fn with a temporary page of the browser on the origin. The requests of the page are
+// answered with an empty document, so that the origin is never requested.
+func (parent *Runner) withOrigin(origin string, fn func(page *rod.Page) error) error {
+	page, err := parent.B.PageE("")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = page.CloseE() }()
+
+	session := string(page.SessionID)
+	stop := parent.eachEvent(func(id string) bool { return id == session }, nil, func(e *cdp.Event) {
+		paused := &proto.FetchRequestPaused{}
+		if rod.Event(e, paused) {
+			_ = proto.FetchFulfillRequest{
+				RequestID:       paused.RequestID,
+				ResponseCode:    200,
+				ResponseHeaders: []*proto.FetchHeaderEntry{{Name: "Content-Type", Value: "text/html"}},
+			}.Call(page)
+		}
+	})
+	defer stop()
+
+	if err := (proto.FetchEnable{}).Call(page); err != nil {
+		return err
+	}
+	if err := page.NavigateE(origin); err != nil {
+		return err
+	}
+	if err := page.WaitLoadE(); err != nil {
+		return err
+	}
+	return fn(page)
+}
+
+// restoreSession sets the session storage of the origin when the current page navigates to it,
+// and right away when now is true
+func (parent *Runner) restoreSession(item *OriginState, now bool) error {
+	origin, _ := json.Marshal(item.Origin)
+	items, _ := json.Marshal(item.SessionStorage)
+	marker, _ := json.Marshal(stateMarker)
+	js := `(origin, items, marker) => {
+		if (location.origin !== origin || sessionStorage.getItem(marker) !== null) return
+		for (const [key, value] of Object.entries(items)) sessionStorage.setItem(key, value)
+		sessionStorage.setItem(marker, '')
+	}`
+
+	if err := (proto.PageEnable{}).Call(parent.P); err != nil {
+		return err
+	}
+	_, err := proto.PageAddScriptToEvaluateOnNewDocument{
+		Source: fmt.Sprintf(`(%s)(%s, %s, %s)`, js, origin, items, marker),
+	}.Call(parent.P)
+	if err != nil || !now {
+		return err
+	}
+
+	_, err = parent.P.EvalE(true, "", js, rod.Array{item.Origin, item.SessionStorage, stateMarker})
+	return err
+}
+
+// storageItems returns every key and value of the local or session storage of the page
+func storageItems(page *rod.Page, storage string) (map[string]string, error) {
+	res, err := evalStorage(page, storage, "get", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	items := map[string]string{}
+	for key, value := range res.(map[string]interface{}) {
+		items[key], _ = value.(string)
+	}
+	return items, nil
+}
+
+func loadStateAction(ra runtimeAction, act Action) interface{} {
+	path, ok := act["path"].(string)
+	if !ok {
+		return ra.err("a 'path' key (type string) is required to be present")
+	}
+
+	if err := ra.runner.LoadState(path); err != nil {
+		return ra.err("could not load the state:", err)
+	}
+	return nil
+}
+
+func saveStateAction(ra runtimeAction, act Action) interface{} {
+	path, ok := act["path"].(string)
+	if !ok {
+		return ra.err("a 'path' key (type string) is required to be present")
+	}
+
+	origins := []string{}
+	if list, ok := act["origins"].([]interface{}); ok {
+		for _, origin := range list {
+			str, ok := origin.(string)
+			if !ok {
+				return ra.err("origins must be an array of strings")
+			}
+			origins = append(origins, str)
+		}
+	}
+
+	if err := ra.runner.SaveState(path, origins...); err != nil {
+		return ra.err("could not save the state:", err)
+	}
+	return path
+}
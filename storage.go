@@ -0,0 +1,149 @@
+package wayang
+
+import (
+	"encoding/json"
+
+	"github.com/go-rod/rod"
+)
+
+// storageJS runs fn with the localStorage or sessionStorage of the current origin
+const storageJS = `(type, fn, ...args) => {
+	const storage = type === 'session' ? window.sessionStorage : window.localStorage
+	switch (fn) {
+	case 'get':
+		if (args[0] !== null) return storage.getItem(args[0])
+		const items = {}
+		for (let i = 0; i < storage.length; i++) {
+			const key = storage.key(i)
+			items[key] = storage.getItem(key)
+		}
+		return items
+	case 'set':
+		for (const [key, value] of Object.entries(args[0])) storage.setItem(key, value)
+		return null
+	case 'remove':
+		for (const key of args[0]) storage.removeItem(key)
+		return null
+	case 'clear':
+		storage.clear()
+		return null
+	}
+}`
+
+// storage calls a function of storageJS on the storage that the action selects
+func (ra runtimeAction) storage(act Action, fn string, args ...interface{}) (interface{}, *RuntimeError) {
+	storage := "local"
+	if value, ok := act["storage"]; ok {
+		storage, _ = value.(string)
+		if storage != "local" && storage != "session" {
+			err := ra.err("storage must be either local or session")
+			return nil, &err
+		}
+	}
+
+	res, err := evalStorage(ra.page, storage, fn, args...)
+	if err != nil {
+		rErr := ra.err("could not access the "+storage+" storage:", err)
+		return nil, &rErr
+	}
+	return res, nil
+}
+
+// evalStorage calls a function of storageJS on the local or session storage of the page
+func evalStorage(page *rod.Page, storage, fn string, args ...interface{}) (interface{}, error) {
+	res, err := page.EvalE(true, "", storageJS, append(rod.Array{storage, fn}, args...))
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	err = json.Unmarshal([]byte(res.Value.Raw), &value)
+	return value, err
+}
+
+// storageValue turns a value into the string that a storage keeps, anything other than a string is kept as JSON
+func storageValue(value interface{}) (string, error) {
+	if str, ok := value.(string); ok {
+		return str, nil
+	}
+	bin, err := json.Marshal(value)
+	return string(bin), err
+}
+
+func clearStorageAction(ra runtimeAction, act Action) interface{} {
+	if _, err := ra.storage(act, "clear"); err != nil {
+		return *err
+	}
+	return nil
+}
+
+func getStorageAction(ra runtimeAction, act Action) interface{} {
+	var key interface{}
+	if value, ok := act["key"]; ok {
+		if key, ok = value.(string); !ok {
+			return ra.err("key must be a string")
+		}
+	}
+
+	res, err := ra.storage(act, "get", key)
+	if err != nil {
+		return *err
+	}
+
+	switch value := res.(type) {
+	case string:
+		return parseBody(value)
+	case map[string]interface{}:
+		for k, item := range value {
+			value[k] = parseBody(item.(string))
+		}
+	}
+	return res
+}
+
+func removeStorageAction(ra runtimeAction, act Action) interface{} {
+	keys := []interface{}{}
+	switch value := act["key"].(type) {
+	case string:
+		keys = append(keys, value)
+	case []interface{}:
+		keys = value
+	default:
+		return ra.err("key must be a string, or an array of strings")
+	}
+
+	if _, err := ra.storage(act, "remove", keys); err != nil {
+		return *err
+	}
+	return nil
+}
+
+func setStorageAction(ra runtimeAction, act Action) interface{} {
+	items := map[string]string{}
+	if values, ok := act["items"].(map[string]interface{}); ok {
+		for key, value := range values {
+			str, err := storageValue(value)
+			if err != nil {
+				return ra.err("could not convert the value of "+key+":", err)
+			}
+			items[key] = str
+		}
+	}
+
+	if key, ok := act["key"].(string); ok {
+		str, err := storageValue(act["value"])
+		if err != nil {
+			return ra.err("could not convert the value of "+key+":", err)
+		}
+		items[key] = str
+	}
+
+	if len(items) == 0 {
+		return ra.err("a 'key' and 'value', or 'items' are required to be present")
+	}
+
+	if _, err := ra.storage(act, "set", items); err != nil {
+		return *err
+	}
+	return nil
+}
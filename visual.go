@@ -0,0 +1,206 @@
+package wayang
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/ysmood/kit"
+)
+
+// hides the element while keeping its layout, the previous inline opacity is kept to be restored
+const maskJS = `() => {
+	this.setAttribute('data-wayang-opacity', this.style.opacity)
+	this.style.setProperty('opacity', '0', 'important')
+}`
+
+const unmaskJS = `() => {
+	this.style.opacity = this.getAttribute('data-wayang-opacity')
+	this.removeAttribute('data-wayang-opacity')
+}`
+
+func matchScreenshotAction(ra runtimeAction, act Action) interface{} {
+	run := ra.runner
+
+	name, ok := act["name"].(string)
+	if !ok {
+		return ra.err("a 'name' key (type string) is required to be present")
+	}
+
+	threshold, _ := act["threshold"].(float64)
+	if threshold < 0 || threshold > 1 {
+		return ra.err("the 'threshold' value must be between 0 and 1")
+	}
+	tolerance, _ := act["tolerance"].(float64)
+	if tolerance < 0 || tolerance > 255 {
+		return ra.err("the 'tolerance' value must be between 0 and 255")
+	}
+
+	unmask, rErr := ra.mask(act["ignore"])
+	if rErr != nil {
+		return *rErr
+	}
+	bin, rErr := ra.screenshot(act, proto.PageCaptureScreenshotFormatPng, -1)
+	unmask()
+	if rErr != nil {
+		return *rErr
+	}
+
+	baseline := filepath.Join(run.SnapshotsDir, name+".png")
+	if run.UpdateSnapshots || !kit.FileExists(baseline) {
+		if err := kit.OutputFile(baseline, bin, nil); err != nil {
+			return ra.err("could not write the baseline screenshot:", err)
+		}
+		run.Info("baseline screenshot written to " + baseline)
+		return nil
+	}
+
+	expected, err := readPNG(baseline)
+	if err != nil {
+		return ra.err("could not read the baseline screenshot:", err)
+	}
+	actual, err := png.Decode(bytes.NewReader(bin))
+	if err != nil {
+		return ra.err("could not decode the screenshot:", err)
+	}
+
+	if expected.Bounds().Size() != actual.Bounds().Size() {
+		return ra.err(fmt.Sprintf(
+			"screenshot size %v does not match the baseline size %v",
+			actual.Bounds().Size(), expected.Bounds().Size(),
+		))
+	}
+
+	diff, ratio := diffImages(expected, actual, uint8(tolerance))
+	if ratio <= threshold {
+		return nil
+	}
+
+	diffPath := run.artifact(name + ".diff.png")
+	actualPath := run.artifact(name + ".actual.png")
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, diff); err != nil {
+		return ra.err("could not encode the diff image:", err)
+	}
+	if err := kit.OutputFile(diffPath, buf.Bytes(), nil); err != nil {
+		return ra.err("could not write the diff image:", err)
+	}
+	if err := kit.OutputFile(actualPath, bin, nil); err != nil {
+		return ra.err("could not write the screenshot:", err)
+	}
+	run.attach(actualPath)
+	run.attach(diffPath)
+
+	return ra.err(fmt.Sprintf(
+		"screenshot differs from the baseline %s by %.2f%% (threshold %.2f%%), diff written to %s",
+		baseline, ratio*100, threshold*100, diffPath,
+	))
+}
+
+// mask hides the elements of the selectors, the returned function shows them again
+func (ra runtimeAction) mask(selectors interface{}) (func(), *RuntimeError) {
+	run := ra.runner
+
+	var list []string
+	switch typed := selectors.(type) {
+	case nil:
+	case []string:
+		list = typed
+	case []interface{}:
+		for _, item := range typed {
+			str, ok := item.(string)
+			if !ok {
+				err := ra.err("expected the 'ignore' key to be a list of selectors, got", item)
+				return nil, &err
+			}
+			list = append(list, str)
+		}
+	default:
+		err := ra.err("expected the 'ignore' key to be a list of selectors, got", selectors)
+		return nil, &err
+	}
+
+	masked := []func(){}
+	unmask := func() {
+		for _, fn := range masked {
+			fn()
+		}
+	}
+
+	for _, item := range list {
+		sel, ok := run.sel(item)
+		if !ok {
+			unmask()
+			err := ra.err("could not find a custom selector defined with the specified value")
+			return nil, &err
+		}
+
+		for _, element := range ra.page.ElementsX(sel) {
+			element := element
+			element.Eval(maskJS)
+			masked = append(masked, func() {
+				kit.Try(func() { element.Eval(unmaskJS) })
+			})
+		}
+	}
+
+	return unmask, nil
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	return png.Decode(f)
+}
+
+// diffImages compares two images of the same size. A pixel is different when any channel differs
+// by more than the tolerance. It returns an image that highlights the different pixels in red over
+// a faded copy of the expected image, and the ratio of different pixels.
+func diffImages(expected, actual image.Image, tolerance uint8) (*image.RGBA, float64) {
+	bounds := expected.Bounds()
+	offset := actual.Bounds().Min.Sub(bounds.Min)
+	diff := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	highlight := color.RGBA{R: 255, A: 255}
+
+	count := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			a := color.RGBAModel.Convert(expected.At(x, y)).(color.RGBA)
+			b := color.RGBAModel.Convert(actual.At(x+offset.X, y+offset.Y)).(color.RGBA)
+
+			pt := image.Pt(x-bounds.Min.X, y-bounds.Min.Y)
+			if channelDiff(a.R, b.R) > tolerance || channelDiff(a.G, b.G) > tolerance ||
+				channelDiff(a.B, b.B) > tolerance || channelDiff(a.A, b.A) > tolerance {
+				count++
+				diff.SetRGBA(pt.X, pt.Y, highlight)
+				continue
+			}
+
+			gray := uint8((uint16(a.R) + uint16(a.G) + uint16(a.B)) / 3)
+			faded := 255 - (255-gray)/4
+			diff.SetRGBA(pt.X, pt.Y, color.RGBA{R: faded, G: faded, B: faded, A: 255})
+		}
+	}
+
+	total := bounds.Dx() * bounds.Dy()
+	if total == 0 {
+		return diff, 0
+	}
+	return diff, float64(count) / float64(total)
+}
+
+func channelDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
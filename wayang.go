@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/go-rod/rod"
 	"github.com/go-rod/rod/lib/cdp"
 	"github.com/go-rod/rod/lib/defaults"
 	"github.com/go-rod/rod/lib/launcher"
+	"github.com/go-rod/rod/lib/proto"
 	"github.com/ysmood/kit"
 )
 
@@ -47,20 +49,104 @@ func NewRunner() *Runner {
 }
 
 func (parent *Runner) RunProgram(program Program) (interface{}, *RuntimeError) {
+	// the imports of a program that wasn't read from a file are relative to the working directory
+	program, err := program.ResolveImports(".")
+	if err != nil {
+		ra := runtimeAction{runner: parent, source: "imports"}
+		rErr := ra.err("could not resolve the imports:", err)
+		return nil, &rErr
+	}
 	parent.program = program
 
+	parent.soft = nil
+	parent.steps = []StepResult{}
+	for i, action := range program.Steps {
+		name, _ := action["action"].(string)
+		parent.steps = append(parent.steps, StepResult{
+			Source: fmt.Sprintf("root[%d]", i),
+			Action: name,
+			Status: StepSkipped,
+		})
+	}
+
+	// the requests and dialogs are logged for each program, so that a runner that is reused doesn't match
+	// the ones of the previous programs
+	parent.network = newNetworkLog()
+	defer parent.network.record(parent)()
+
+	if program.Dialogs != nil && !program.Dialogs.valid() {
+		ra := runtimeAction{runner: parent, source: "dialogs"}
+		rErr := ra.err("dialogs.handle must be accept, dismiss or none")
+		return nil, &rErr
+	}
+	parent.dialogs = newDialogLog()
+	defer parent.dialogs.record(parent)()
+
+	if program.Device != nil {
+		if err := parent.Emulate(*program.Device); err != nil {
+			ra := runtimeAction{runner: parent, source: "device"}
+			rErr := ra.err("could not emulate the device:", err)
+			return nil, &rErr
+		}
+	}
+	if program.Environment != nil {
+		if err := parent.SetEnvironment(*program.Environment); err != nil {
+			ra := runtimeAction{runner: parent, source: "environment"}
+			rErr := ra.err("could not emulate the environment:", err)
+			return nil, &rErr
+		}
+	}
+
+	for i := range program.Mocks {
+		if err := parent.Route(&program.Mocks[i]); err != nil {
+			ra := runtimeAction{runner: parent, source: fmt.Sprintf("mocks[%d]", i)}
+			rErr := ra.err("could not add the mock:", err)
+			return nil, &rErr
+		}
+	}
+
 	var res interface{}
 	for i, action := range parent.program.Steps {
 		source := fmt.Sprintf("root[%d]", i)
+		step := &parent.steps[i]
+		parent.screenshots = nil
+		soft := len(parent.soft)
+		start := time.Now()
+
 		res = parent.runAction(action, source)
+
+		step.Duration = time.Since(start)
+		step.Screenshots = parent.screenshots
+		step.Status = StepPassed
+		if len(parent.soft) > soft {
+			step.Status = StepFailed
+			step.Err = &parent.soft[len(parent.soft)-1]
+		}
 		if err, ok := res.(RuntimeError); ok {
+			err.snapshot = parent.snapshot()
+			err.failures = parent.failures()
+			if failure := err.Assertion(); failure != nil {
+				err.failures = append(err.failures, failure)
+			}
+			step.Status = StepFailed
+			step.Err = &err
 			return nil, &err
 		}
 	}
 
+	if len(parent.soft) > 0 {
+		err := parent.softReport()
+		err.snapshot = parent.snapshot()
+		return nil, &err
+	}
 	return res, nil
 }
 
+// Steps returns the results of the steps of the last program that the runner ran
+func (parent *Runner) Steps() []StepResult {
+	return append([]StepResult{}, parent.steps...)
+}
+
 func RunProgram(program Program) (interface{}, *RuntimeError) {
 	return NewRunner().RunProgram(program)
 }
@@ -89,8 +175,43 @@ func (parent *Runner) RunAction(action Action) (interface{}, *RuntimeError) {
 	})
 }
 
+// Incognito returns a runner on a new page of a new incognito browser context, which shares no cookies, storage or
+// cache with the other runners. Closing it disposes the context, and leaves the browser open.
+func (parent *Runner) Incognito() (*Runner, error) {
+	browser, err := parent.B.IncognitoE()
+	if err != nil {
+		return nil, err
+	}
+	page, err := browser.PageE("")
+	if err != nil {
+		_ = proto.TargetDisposeBrowserContext{BrowserContextID: browser.BrowserContextID}.Call(parent.B)
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(parent.B.GetContext())
+	return &Runner{
+		B:               browser,
+		P:               page,
+		ENV:             map[string]interface{}{},
+		Context:         ctx,
+		Canceller:       cancel,
+		Logger:          parent.Logger,
+		ArtifactsDir:    parent.ArtifactsDir,
+		SnapshotsDir:    parent.SnapshotsDir,
+		UpdateSnapshots: parent.UpdateSnapshots,
+		program:         Program{},
+		incognito:       true,
+	}, nil
+}
+
 func (parent *Runner) Close() {
-	parent.B.Close()
+	parent.StopHAR()
+	parent.Unroute(&Route{})
+	if parent.incognito {
+		_ = proto.TargetDisposeBrowserContext{BrowserContextID: parent.B.BrowserContextID}.Call(parent.B)
+	} else {
+		parent.B.Close()
+	}
 	parent.Canceller()
 }
 
@@ -110,10 +231,36 @@ func (re *RuntimeError) Source() string {
 	return re.source
 }
 
+// Snapshot returns the state of the page when the error occurred, it is nil if the page was not available.
+func (re *RuntimeError) Snapshot() *PageSnapshot {
+	return re.snapshot
+}
+
 func (re *RuntimeError) ErrorRaw() interface{} {
 	return re.err
 }
 
+// Failures returns the failed assertions of the program, the soft ones followed by the one that stopped it, if any
+func (re *RuntimeError) Failures() []*AssertionFailure {
+	if re.failures == nil {
+		if failure := re.Assertion(); failure != nil {
+			return []*AssertionFailure{failure}
+		}
+	}
+	return re.failures
+}
+
+// Assertion returns the failure of the assert action that returned the error, it is nil for the other errors
+func (re *RuntimeError) Assertion() *AssertionFailure {
+	errs, _ := re.err.([]interface{})
+	for _, err := range errs {
+		if failure, ok := err.(*AssertionFailure); ok {
+			return failure
+		}
+	}
+	return nil
+}
+
 func (re *RuntimeError) Error() string {
 	return fmt.Sprintln(re.err)
 }